@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// apiKeyCredential is the name of the systemd credential that holds
+// the API key, see LoadCredential= in systemd.exec(5).
+const apiKeyCredential = "monibot-api-key"
+
+// apiKeySources are the ways to give the API key: the key itself, a
+// file that contains it, or a command that prints it.
+type apiKeySources struct {
+	Key     string
+	File    string
+	Command string
+}
+
+// resolveApiKey returns the API key and where it came from. Sources
+// given via flags win over sources given via environment, so that an
+// explicit -apiKeyFile is not overridden by MONIBOT_API_KEY. Within
+// each, the key wins, then file and command. If none is given, it
+// reads the systemd credential in $CREDENTIALS_DIRECTORY.
+func resolveApiKey(flags, env apiKeySources) (string, string, error) {
+	for _, s := range []struct {
+		sources apiKeySources
+		name    string
+	}{{flags, "flag"}, {env, "environment"}} {
+		switch {
+		case s.sources.Key != "":
+			return s.sources.Key, s.name, nil
+		case s.sources.File != "":
+			key, err := readApiKeyFile(s.sources.File, true)
+			return key, "file " + s.sources.File, err
+		case s.sources.Command != "":
+			key, err := runApiKeyCommand(s.sources.Command)
+			return key, "command", err
+		}
+	}
+	if dir := os.Getenv("CREDENTIALS_DIRECTORY"); dir != "" {
+		filename := filepath.Join(dir, apiKeyCredential)
+		if _, err := os.Stat(filename); err == nil {
+			// systemd takes care of the permissions
+			key, err := readApiKeyFile(filename, false)
+			return key, "systemd credential " + apiKeyCredential, err
+		}
+	}
+	return "", "", nil
+}
+
+// readApiKeyFile reads an API key from a file. If checkPerm is true,
+// it refuses files that are readable by everyone.
+func readApiKeyFile(filename string, checkPerm bool) (string, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return "", err
+	}
+	// windows has no unix permission bits
+	if checkPerm && runtime.GOOS != "windows" && info.Mode().Perm()&0004 != 0 {
+		return "", fmt.Errorf("api key file %s is readable by everyone (mode %s), run 'chmod o-r %s'", filename, info.Mode().Perm(), filename)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return "", fmt.Errorf("api key file %s is empty", filename)
+	}
+	return key, nil
+}
+
+// runApiKeyCommand runs a shell command, like 'pass show monibot', and
+// returns the first line of its stdout as API key.
+func runApiKeyCommand(command string) (string, error) {
+	args := shellArgs(command)
+	cmd := exec.Command(args[0], args[1:]...)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("api key command failed: %w", err)
+	}
+	key, _, _ := strings.Cut(stdout.String(), "\n")
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", fmt.Errorf("api key command printed no api key")
+	}
+	return key, nil
+}
+
+// maskSecret masks a secret for display, it shows at most the
+// last 4 characters, e.g. "****wxyz". Secrets shorter than 12
+// characters are masked completely.
+func maskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if len(secret) < 12 {
+		return "****"
+	}
+	return "****" + secret[len(secret)-4:]
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestResolveApiKey(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CREDENTIALS_DIRECTORY", "")
+	// none
+	key, source, err := resolveApiKey(apiKeySources{}, apiKeySources{})
+	assertNil(t, err)
+	assertEqual(t, "", key)
+	assertEqual(t, "", source)
+	// key wins
+	keyFile := filepath.Join(dir, "apikey")
+	assertNil(t, os.WriteFile(keyFile, []byte("from-file\n"), 0600))
+	key, source, err = resolveApiKey(apiKeySources{"from-flag", keyFile, "echo from-command"}, apiKeySources{})
+	assertNil(t, err)
+	assertEqual(t, "from-flag", key)
+	assertEqual(t, "flag", source)
+	// file
+	key, source, err = resolveApiKey(apiKeySources{File: keyFile, Command: "echo from-command"}, apiKeySources{})
+	assertNil(t, err)
+	assertEqual(t, "from-file", key)
+	assertEqual(t, "file "+keyFile, source)
+	// flags win over environment
+	key, source, err = resolveApiKey(apiKeySources{File: keyFile}, apiKeySources{Key: "from-env"})
+	assertNil(t, err)
+	assertEqual(t, "from-file", key)
+	assertEqual(t, "file "+keyFile, source)
+	key, source, err = resolveApiKey(apiKeySources{}, apiKeySources{Key: "from-env", File: keyFile})
+	assertNil(t, err)
+	assertEqual(t, "from-env", key)
+	assertEqual(t, "environment", source)
+	// systemd credential
+	assertNil(t, os.WriteFile(filepath.Join(dir, apiKeyCredential), []byte("from-credential"), 0600))
+	t.Setenv("CREDENTIALS_DIRECTORY", dir)
+	key, source, err = resolveApiKey(apiKeySources{}, apiKeySources{})
+	assertNil(t, err)
+	assertEqual(t, "from-credential", key)
+	assertEqual(t, "systemd credential monibot-api-key", source)
+}
+
+func TestReadApiKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "apikey")
+	assertNil(t, os.WriteFile(filename, []byte("  007 \n"), 0600))
+	key, err := readApiKeyFile(filename, true)
+	assertNil(t, err)
+	assertEqual(t, "007", key)
+	assertNil(t, os.WriteFile(filename, []byte("\n"), 0600))
+	_, err = readApiKeyFile(filename, true)
+	assertEqual(t, fmt.Sprintf("api key file %s is empty", filename), err.Error())
+	_, err = readApiKeyFile(filepath.Join(dir, "nope"), true)
+	assertEqual(t, true, os.IsNotExist(err))
+	if runtime.GOOS != "windows" {
+		assertNil(t, os.WriteFile(filename, []byte("007"), 0600))
+		assertNil(t, os.Chmod(filename, 0644))
+		_, err = readApiKeyFile(filename, true)
+		assertEqual(t, fmt.Sprintf("api key file %s is readable by everyone (mode -rw-r--r--), run 'chmod o-r %s'", filename, filename), err.Error())
+		key, err = readApiKeyFile(filename, false)
+		assertNil(t, err)
+		assertEqual(t, "007", key)
+	}
+}
+
+func TestRunApiKeyCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	key, err := runApiKeyCommand("printf ' 007 \\nsecond line\\n'")
+	assertNil(t, err)
+	assertEqual(t, "007", key)
+	_, err = runApiKeyCommand("true")
+	assertEqual(t, "api key command printed no api key", err.Error())
+	_, err = runApiKeyCommand("exit 3")
+	assertEqual(t, "api key command failed: exit status 3", err.Error())
+}
+
+func TestMaskSecret(t *testing.T) {
+	assertEqual(t, "", maskSecret(""))
+	assertEqual(t, "****", maskSecret("007"))
+	assertEqual(t, "****", maskSecret("0123456789a"))
+	assertEqual(t, "****89ab", maskSecret("0123456789ab"))
+}
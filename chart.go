@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/cvilsmeier/monibot-go"
+)
+
+// chart defaults
+const (
+	defaultChartWidth  = 60
+	defaultChartHeight = 8
+)
+
+// chartBlocks are unicode blocks from 1/8 to 8/8 height.
+var chartBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sampleFields are the machine sample fields that can be charted.
+var sampleFields = map[string]func(s monibot.MachineSample) float64{
+	"cpu":       func(s monibot.MachineSample) float64 { return float64(s.CpuPercent) },
+	"mem":       func(s monibot.MachineSample) float64 { return float64(s.MemPercent) },
+	"disk":      func(s monibot.MachineSample) float64 { return float64(s.DiskPercent) },
+	"load1":     func(s monibot.MachineSample) float64 { return s.Load1 },
+	"load5":     func(s monibot.MachineSample) float64 { return s.Load5 },
+	"load15":    func(s monibot.MachineSample) float64 { return s.Load15 },
+	"diskRead":  func(s monibot.MachineSample) float64 { return float64(s.DiskRead) },
+	"diskWrite": func(s monibot.MachineSample) float64 { return float64(s.DiskWrite) },
+	"netRecv":   func(s monibot.MachineSample) float64 { return float64(s.NetRecv) },
+	"netSend":   func(s monibot.MachineSample) float64 { return float64(s.NetSend) },
+}
+
+// sampleFieldNames lists sampleFields in display order.
+var sampleFieldNames = []string{"cpu", "mem", "disk", "load1", "load5", "load15", "diskRead", "diskWrite", "netRecv", "netSend"}
+
+// ChartSummary holds summary statistics of chart values.
+type ChartSummary struct {
+	Count int
+	Min   float64
+	Max   float64
+	Avg   float64
+	Last  float64
+}
+
+func summarize(values []float64) ChartSummary {
+	if len(values) == 0 {
+		return ChartSummary{}
+	}
+	s := ChartSummary{Count: len(values), Min: values[0], Max: values[0], Last: values[len(values)-1]}
+	var sum float64
+	for _, v := range values {
+		s.Min = math.Min(s.Min, v)
+		s.Max = math.Max(s.Max, v)
+		sum += v
+	}
+	s.Avg = sum / float64(len(values))
+	return s
+}
+
+func (s ChartSummary) String() string {
+	return fmt.Sprintf("min %s, max %s, avg %s, last %s (%d values)", fmtChartValue(s.Min), fmtChartValue(s.Max), fmtChartValue(s.Avg), fmtChartValue(s.Last), s.Count)
+}
+
+// fmtChartValue formats a chart value with at most 2 decimals.
+func fmtChartValue(v float64) string {
+	return fmtFloat(math.Round(v*100) / 100)
+}
+
+// compress averages values into at most width buckets.
+func compress(values []float64, width int) []float64 {
+	if len(values) <= width {
+		return values
+	}
+	buckets := make([]float64, width)
+	for i := range buckets {
+		from := i * len(values) / width
+		to := (i + 1) * len(values) / width
+		var sum float64
+		for _, v := range values[from:to] {
+			sum += v
+		}
+		buckets[i] = sum / float64(to-from)
+	}
+	return buckets
+}
+
+// renderChart renders values as a block chart with height rows.
+// The y-axis starts at zero, or at the min value if it is negative.
+// If ascii is true, it uses '#' instead of unicode blocks.
+func renderChart(values []float64, width, height int, ascii bool) []string {
+	if len(values) == 0 {
+		return []string{"no values"}
+	}
+	values = compress(values, width)
+	summary := summarize(values)
+	lo := math.Min(0, summary.Min)
+	hi := summary.Max
+	if hi <= lo {
+		hi = lo + 1
+	}
+	hiLabel := fmtChartValue(hi)
+	loLabel := fmtChartValue(lo)
+	labelWidth := max(len(hiLabel), len(loLabel))
+	lines := make([]string, 0, height)
+	for row := height - 1; row >= 0; row-- {
+		label := ""
+		switch row {
+		case height - 1:
+			label = hiLabel
+		case 0:
+			label = loLabel
+		}
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "%*s |", labelWidth, label)
+		for _, v := range values {
+			// eighths of this cell that are filled
+			eighths := int(math.Round((v-lo)/(hi-lo)*float64(height*8))) - row*8
+			switch {
+			case eighths <= 0:
+				sb.WriteRune(' ')
+			case ascii && eighths >= 4:
+				sb.WriteRune('#')
+			case ascii:
+				sb.WriteRune(' ')
+			case eighths >= 8:
+				sb.WriteRune(chartBlocks[7])
+			default:
+				sb.WriteRune(chartBlocks[eighths-1])
+			}
+		}
+		lines = append(lines, strings.TrimRight(sb.String(), " "))
+	}
+	return lines
+}
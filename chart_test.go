@@ -0,0 +1,26 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderChart(t *testing.T) {
+	values := []float64{0, 1, 2, 3, 4, 5, 6, 7, 8}
+	lines := renderChart(values, 60, 2, false)
+	assertEqual(t, "8 |     ▂▄▆█\n0 | ▂▄▆█████", strings.Join(lines, "\n"))
+	lines = renderChart(values, 60, 2, true)
+	assertEqual(t, "8 |      ###\n0 |  #######", strings.Join(lines, "\n"))
+	// values are compressed into width buckets
+	lines = renderChart(values, 3, 1, false)
+	assertEqual(t, "7 |▁▅█", strings.Join(lines, "\n"))
+	// no values
+	lines = renderChart(nil, 60, 2, false)
+	assertEqual(t, "no values", strings.Join(lines, "\n"))
+}
+
+func TestSummarize(t *testing.T) {
+	assertEqual(t, "min 0, max 8, avg 4, last 8 (9 values)", summarize([]float64{0, 1, 2, 3, 4, 5, 6, 7, 8}).String())
+	assertEqual(t, "min 0.33, max 1, avg 0.67, last 0.67 (3 values)", summarize([]float64{1, 1.0 / 3, 2.0 / 3}).String())
+	assertEqual(t, "min 0, max 0, avg 0, last 0 (0 values)", summarize(nil).String())
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxDiffCells limits the memory used for diffing. If the changed
+// part of two texts is bigger, the diff shows it as fully replaced.
+const maxDiffCells = 4 * 1024 * 1024
+
+// diffContext is the number of unchanged lines shown around changes.
+const diffContext = 3
+
+// diffOp is a line diff operation: ' ' (keep), '-' (remove) or '+' (add).
+type diffOp struct {
+	kind byte
+	line string
+	a, b int // line index in old and new text
+}
+
+// splitLines splits text into lines, each line keeps its newline.
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines calculates the line operations that turn a into b.
+// It uses a longest common subsequence on the changed middle part.
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	var ops []diffOp
+	ai, bi := 0, 0
+	emit := func(kind byte, line string) {
+		ops = append(ops, diffOp{kind, line, ai, bi})
+		if kind != '+' {
+			ai++
+		}
+		if kind != '-' {
+			bi++
+		}
+	}
+	for _, line := range a[:prefix] {
+		emit(' ', line)
+	}
+	am := a[prefix : len(a)-suffix]
+	bm := b[prefix : len(b)-suffix]
+	n, m := len(am), len(bm)
+	i, j := 0, 0
+	if (n+1)*(m+1) <= maxDiffCells {
+		// lcs[i*(m+1)+j] is the lcs length of am[i:] and bm[j:]
+		lcs := make([]int32, (n+1)*(m+1))
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if am[i] == bm[j] {
+					lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+				} else {
+					lcs[i*(m+1)+j] = max(lcs[(i+1)*(m+1)+j], lcs[i*(m+1)+j+1])
+				}
+			}
+		}
+		for i < n && j < m {
+			switch {
+			case am[i] == bm[j]:
+				emit(' ', am[i])
+				i++
+				j++
+			case lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]:
+				emit('-', am[i])
+				i++
+			default:
+				emit('+', bm[j])
+				j++
+			}
+		}
+	}
+	for ; i < n; i++ {
+		emit('-', am[i])
+	}
+	for ; j < m; j++ {
+		emit('+', bm[j])
+	}
+	for _, line := range a[len(a)-suffix:] {
+		emit(' ', line)
+	}
+	return ops
+}
+
+// unifiedDiff returns a unified diff of two texts, or an
+// empty string if they are equal.
+func unifiedDiff(oldText, newText string) string {
+	ops := diffLines(splitLines(oldText), splitLines(newText))
+	var sb strings.Builder
+	for start := 0; start < len(ops); {
+		// find next change
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		// extend hunk while changes are close to each other
+		end := start
+		for k := start; k < len(ops) && k <= end+2*diffContext; k++ {
+			if ops[k].kind != ' ' {
+				end = k
+			}
+		}
+		from := max(0, start-diffContext)
+		to := min(len(ops), end+1+diffContext)
+		if sb.Len() == 0 {
+			sb.WriteString("--- previous\n+++ current\n")
+		}
+		oldCount, newCount := 0, 0
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(ops[from].a, oldCount), hunkRange(ops[from].b, newCount))
+		for _, op := range ops[from:to] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(withNewline(op.line))
+		}
+		start = to
+	}
+	return sb.String()
+}
+
+// hunkRange formats a hunk range like "3,4" for line index 2 and count 4.
+func hunkRange(index, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", index)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", index+1)
+	}
+	return fmt.Sprintf("%d,%d", index+1, count)
+}
+
+// textWithDiff prepends a diff against the previous text to text.
+// The diff is truncated so that the result fits into maxSize bytes,
+// and omitted if there is no room for it.
+func textWithDiff(previous, text string, maxSize int) string {
+	diff := unifiedDiff(previous, text)
+	if diff == "" {
+		return text
+	}
+	const header = "changes since last upload:\n"
+	budget := maxSize - len(text) - len(header) - 1
+	if budget < len(omittedMarker(len(diff)))+1 {
+		return text
+	}
+	return header + truncateText(diff, budget, truncateTail) + "\n" + text
+}
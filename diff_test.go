@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 20; i++ {
+		lines = append(lines, fmt.Sprintf("line %d\n", i))
+	}
+	oldText := strings.Join(lines, "")
+	// equal texts
+	assertEqual(t, "", unifiedDiff(oldText, oldText))
+	// change, add and remove lines
+	newLines := append([]string{}, lines...)
+	newLines[1] = "line two\n"
+	newLines = append(newLines[:10], newLines[11:]...)
+	newLines = append(newLines, "line 21\n")
+	newText := strings.Join(newLines, "")
+	assertEqual(t, "--- previous\n+++ current\n"+
+		"@@ -1,5 +1,5 @@\n line 1\n-line 2\n+line two\n line 3\n line 4\n line 5\n"+
+		"@@ -8,7 +8,6 @@\n line 8\n line 9\n line 10\n-line 11\n line 12\n line 13\n line 14\n"+
+		"@@ -18,3 +17,4 @@\n line 18\n line 19\n line 20\n+line 21\n", unifiedDiff(oldText, newText))
+	// from and to empty text
+	assertEqual(t, "--- previous\n+++ current\n@@ -0,0 +1,2 @@\n+a\n+b\n", unifiedDiff("", "a\nb\n"))
+	assertEqual(t, "--- previous\n+++ current\n@@ -1 +0,0 @@\n-a\n", unifiedDiff("a\n", ""))
+}
+
+func TestTextWithDiff(t *testing.T) {
+	// no changes
+	assertEqual(t, "a\nb\n", textWithDiff("a\nb\n", "a\nb\n", 1000))
+	// with changes
+	assertEqual(t, "changes since last upload:\n--- previous\n+++ current\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n\na\nc\n", textWithDiff("a\nb\n", "a\nc\n", 1000))
+	// no room for diff
+	assertEqual(t, "a\nc\n", textWithDiff("a\nb\n", "a\nc\n", 40))
+}
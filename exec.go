@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultExecTimeout is the default timeout for commands run by moni.
+const defaultExecTimeout = 1 * time.Minute
+
+// ExecResult is the result of running a command.
+type ExecResult struct {
+	Output   []byte // combined stdout and stderr
+	ExitCode int    // -1 if the command was killed
+	TimedOut bool
+}
+
+// execCommand runs a command and captures its combined output.
+// If the command does not finish within timeout, or ctx is done,
+// it is killed. An error is returned only if the command cannot be
+// started.
+func execCommand(ctx context.Context, args []string, timeout time.Duration) (ExecResult, error) {
+	if len(args) == 0 {
+		return ExecResult{}, fmt.Errorf("empty command")
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	// do not wait forever for child processes that keep the output open
+	cmd.WaitDelay = 1 * time.Second
+	output, err := cmd.CombinedOutput()
+	result := ExecResult{Output: output}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case errors.Is(err, exec.ErrWaitDelay):
+		// the command exited, but a child process kept its output open
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	default:
+		return ExecResult{}, err
+	}
+	result.TimedOut = errors.Is(ctx.Err(), context.DeadlineExceeded)
+	return result, nil
+}
+
+// fmtCommand formats command args for display, quoting args that contain spaces.
+func fmtCommand(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'") {
+			arg = strconv.Quote(arg)
+		}
+		quoted = append(quoted, arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// execText formats the result of a command as machine text,
+// prefixed by a header with hostname, command and exit code.
+// If the text is bigger than maxSize, the command output is
+// truncated with truncateMode.
+func execText(hostname string, args []string, timeout time.Duration, result ExecResult, maxSize int, truncateMode string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "hostname:  %s\n", hostname)
+	fmt.Fprintf(&sb, "command:   %s\n", fmtCommand(args))
+	if result.TimedOut {
+		fmt.Fprintf(&sb, "exit code: %d (timeout after %s)\n", result.ExitCode, fmtDuration(timeout))
+	} else {
+		fmt.Fprintf(&sb, "exit code: %d\n", result.ExitCode)
+	}
+	sb.WriteString("\n")
+	sb.WriteString(truncateText(string(result.Output), maxSize-sb.Len(), truncateMode))
+	return sb.String()
+}
+
+// runCommand runs a command with stdin, stdout and stderr of moni
+// and measures its wall-clock duration. When ctx is done, the command
+// is asked to terminate, and runCommand still waits for its exit code.
+// An error is returned only if the command cannot be started.
+func runCommand(ctx context.Context, args []string) (int, time.Duration, error) {
+	if len(args) == 0 {
+		return 0, 0, fmt.Errorf("empty command")
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return 0, 0, err
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			terminateProcess(cmd.Process)
+		case <-done:
+		}
+	}()
+	err := cmd.Wait()
+	elapsed := time.Since(start)
+	close(done)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return 0, elapsed, err
+		}
+		return exitErr.ExitCode(), elapsed, nil
+	}
+	return 0, elapsed, nil
+}
+
+// durationUnits are the units for 'moni time'
+var durationUnits = map[string]time.Duration{
+	"ms": time.Millisecond,
+	"s":  time.Second,
+}
+
+// durationValue converts d into a value of unit, rounded to the nearest integer.
+func durationValue(d time.Duration, unit string) (int64, error) {
+	u, ok := durationUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid unit %q, must be ms or s", unit)
+	}
+	return int64((d + u/2) / u), nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestExecCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	// ok
+	result, err := execCommand(context.Background(), []string{"sh", "-c", "echo out; echo err >&2"}, 5*time.Second)
+	assertNil(t, err)
+	assertEqual(t, "out\nerr\n", string(result.Output))
+	assertEqual(t, 0, result.ExitCode)
+	assertEqual(t, false, result.TimedOut)
+	// exit code
+	result, err = execCommand(context.Background(), []string{"sh", "-c", "exit 3"}, 5*time.Second)
+	assertNil(t, err)
+	assertEqual(t, 3, result.ExitCode)
+	// timeout
+	result, err = execCommand(context.Background(), []string{"sh", "-c", "sleep 5"}, 100*time.Millisecond)
+	assertNil(t, err)
+	assertEqual(t, -1, result.ExitCode)
+	assertEqual(t, true, result.TimedOut)
+	// a background child keeps the output open
+	result, err = execCommand(context.Background(), []string{"sh", "-c", "sleep 5 & echo hi"}, 10*time.Second)
+	assertNil(t, err)
+	assertEqual(t, "hi\n", string(result.Output))
+	assertEqual(t, 0, result.ExitCode)
+	assertEqual(t, false, result.TimedOut)
+	// stopped
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	result, err = execCommand(ctx, []string{"sh", "-c", "sleep 5"}, 5*time.Second)
+	assertNil(t, err)
+	assertEqual(t, -1, result.ExitCode)
+	assertEqual(t, false, result.TimedOut)
+	// not found
+	_, err = execCommand(context.Background(), []string{"/no/such/command"}, 5*time.Second)
+	assertEqual(t, true, err != nil)
+}
+
+func TestExecText(t *testing.T) {
+	args := []string{"df", "-h", "/var/my files"}
+	text := execText("db1", args, time.Minute, ExecResult{[]byte("output\n"), 0, false}, 1000, truncateTail)
+	assertEqual(t, "hostname:  db1\ncommand:   df -h \"/var/my files\"\nexit code: 0\n\noutput\n", text)
+	text = execText("db1", args, time.Minute, ExecResult{nil, -1, true}, 1000, truncateTail)
+	assertEqual(t, "hostname:  db1\ncommand:   df -h \"/var/my files\"\nexit code: -1 (timeout after 1m)\n\n", text)
+	// output is truncated, header is kept
+	text = execText("db1", args, time.Minute, ExecResult{[]byte("line 1\nline 2\nline 3\nline 4\nline 5\nline 6\nline 7\nline 8\n"), 0, false}, 100, truncateHead)
+	assertEqual(t, "hostname:  db1\ncommand:   df -h \"/var/my files\"\nexit code: 0\n\n... 49 bytes omitted ...\nline 8\n", text)
+}
+
+func TestRunCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	exitCode, elapsed, err := runCommand(context.Background(), []string{"sh", "-c", "sleep 0.1"})
+	assertNil(t, err)
+	assertEqual(t, 0, exitCode)
+	assertEqual(t, true, elapsed >= 100*time.Millisecond)
+	exitCode, _, err = runCommand(context.Background(), []string{"sh", "-c", "exit 4"})
+	assertNil(t, err)
+	assertEqual(t, 4, exitCode)
+	// stopped: the command is terminated, and its exit code is kept
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	exitCode, _, err = runCommand(ctx, []string{"sh", "-c", "trap 'exit 5' TERM; sleep 5 & wait"})
+	assertNil(t, err)
+	assertEqual(t, 5, exitCode)
+	_, _, err = runCommand(context.Background(), []string{"/no/such/command"})
+	assertEqual(t, true, err != nil)
+}
+
+func TestDurationValue(t *testing.T) {
+	value, err := durationValue(1499*time.Microsecond, "ms")
+	assertNil(t, err)
+	assertEqual(t, int64(1), value)
+	value, err = durationValue(1500*time.Microsecond, "ms")
+	assertNil(t, err)
+	assertEqual(t, int64(2), value)
+	value, err = durationValue(90*time.Second, "s")
+	assertNil(t, err)
+	assertEqual(t, int64(90), value)
+	_, err = durationValue(time.Second, "h")
+	assertEqual(t, `invalid unit "h", must be ms or s`, err.Error())
+}
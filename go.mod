@@ -5,6 +5,7 @@ go 1.23
 require (
 	github.com/cvilsmeier/monibot-go v0.2.0
 	github.com/shirou/gopsutil/v4 v4.24.12
+	golang.org/x/sys v0.28.0
 )
 
 require (
@@ -15,5 +16,4 @@ require (
 	github.com/tklauser/go-sysconf v0.3.12 // indirect
 	github.com/tklauser/numcpus v0.6.1 // indirect
 	github.com/yusufpapurcu/wmi v1.2.4 // indirect
-	golang.org/x/sys v0.28.0 // indirect
 )
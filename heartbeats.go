@@ -0,0 +1,69 @@
+package main
+
+import (
+	"time"
+
+	"github.com/cvilsmeier/monibot-go"
+)
+
+// HeartbeatLog records the time of the last heartbeat that
+// was sent from this host, for each watchdog.
+// The Monibot API does not report heartbeat times, so this
+// is the only source moni has for watchdog status.
+type HeartbeatLog struct {
+	filename string
+}
+
+func NewHeartbeatLog(filename string) *HeartbeatLog {
+	return &HeartbeatLog{filename}
+}
+
+// Load loads the heartbeat times, in unix millis, keyed by watchdog id.
+// A missing or broken file results in an empty map.
+func (l *HeartbeatLog) Load() map[string]int64 {
+	tstamps := make(map[string]int64)
+	if err := loadState(l.filename, &tstamps); err != nil {
+		return make(map[string]int64)
+	}
+	return tstamps
+}
+
+// Record stores the time of a heartbeat for a watchdog.
+func (l *HeartbeatLog) Record(watchdogId string, tstamp int64) error {
+	unlock, err := lockState(l.filename)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	tstamps := l.Load()
+	tstamps[watchdogId] = tstamp
+	return saveState(l.filename, tstamps)
+}
+
+// WatchdogStatus is the status of a watchdog, as seen from this host.
+type WatchdogStatus struct {
+	Watchdog      monibot.Watchdog
+	LastHeartbeat int64 // unix millis, 0 if unknown
+	Overdue       time.Duration
+}
+
+// IsOverdue returns true if the last heartbeat is older than the watchdog interval.
+func (s WatchdogStatus) IsOverdue() bool {
+	return s.Overdue > 0
+}
+
+// watchdogStatuses calculates the status of watchdogs.
+func watchdogStatuses(watchdogs []monibot.Watchdog, heartbeats map[string]int64, now int64) []WatchdogStatus {
+	statuses := make([]WatchdogStatus, 0, len(watchdogs))
+	for _, watchdog := range watchdogs {
+		status := WatchdogStatus{Watchdog: watchdog}
+		if last, ok := heartbeats[watchdog.Id]; ok {
+			status.LastHeartbeat = last
+			if overdue := now - last - watchdog.IntervalMillis; overdue > 0 {
+				status.Overdue = time.Duration(overdue) * time.Millisecond
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
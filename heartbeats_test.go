@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cvilsmeier/monibot-go"
+)
+
+func TestHeartbeatLog(t *testing.T) {
+	heartbeatLog := NewHeartbeatLog(filepath.Join(t.TempDir(), "heartbeats.json"))
+	assertEqual(t, 0, len(heartbeatLog.Load()))
+	assertNil(t, heartbeatLog.Record("w1", 1000))
+	assertNil(t, heartbeatLog.Record("w2", 2000))
+	assertNil(t, heartbeatLog.Record("w1", 3000))
+	heartbeats := heartbeatLog.Load()
+	assertEqual(t, 2, len(heartbeats))
+	assertEqual(t, int64(3000), heartbeats["w1"])
+	assertEqual(t, int64(2000), heartbeats["w2"])
+}
+
+func TestHeartbeatLogConcurrent(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "heartbeats.json")
+	// like cron jobs for different watchdogs
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assertNil(t, NewHeartbeatLog(filename).Record(fmt.Sprintf("w%d", i), int64(i)))
+		}()
+	}
+	wg.Wait()
+	assertEqual(t, 20, len(NewHeartbeatLog(filename).Load()))
+	// no temp files are left
+	entries, err := os.ReadDir(filepath.Dir(filename))
+	assertNil(t, err)
+	assertEqual(t, 2, len(entries))
+}
+
+func TestWatchdogStatuses(t *testing.T) {
+	watchdogs := []monibot.Watchdog{
+		{Id: "w1", Name: "Backup", IntervalMillis: 60_000},
+		{Id: "w2", Name: "Cron", IntervalMillis: 60_000},
+		{Id: "w3", Name: "Other", IntervalMillis: 60_000},
+	}
+	heartbeats := map[string]int64{
+		"w1": 100_000,
+		"w2": 10_000,
+	}
+	statuses := watchdogStatuses(watchdogs, heartbeats, 130_000)
+	assertEqual(t, 3, len(statuses))
+	// w1 is ok
+	assertEqual(t, int64(100_000), statuses[0].LastHeartbeat)
+	assertEqual(t, false, statuses[0].IsOverdue())
+	// w2 is overdue
+	assertEqual(t, int64(10_000), statuses[1].LastHeartbeat)
+	assertEqual(t, true, statuses[1].IsOverdue())
+	assertEqual(t, 60*time.Second, statuses[1].Overdue)
+	// w3 is unknown
+	assertEqual(t, int64(0), statuses[2].LastHeartbeat)
+	assertEqual(t, false, statuses[2].IsOverdue())
+}
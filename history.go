@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cvilsmeier/monibot-go"
+)
+
+// historyRetention is the time history entries are kept.
+const historyRetention = 31 * 24 * time.Hour
+
+// History is an append-only log of data that was sent from this
+// host. It is stored as JSON lines in the state directory.
+// The Monibot API does not provide historical data, so this
+// is the only source moni has for history.
+type History struct {
+	filename string
+}
+
+func NewHistory(filename string) *History {
+	return &History{filename}
+}
+
+// historyIdPattern matches the ids that may be used in history file
+// names. Monibot ids are hex strings.
+var historyIdPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// historyFilename returns the history file of a machine or metric in
+// stateDir, e.g. 'history/machine-<id>.jsonl'. It rejects ids that
+// could escape the history directory.
+func historyFilename(stateDir, kind, id string) (string, error) {
+	if !historyIdPattern.MatchString(id) {
+		return "", fmt.Errorf("invalid %sId %q", kind, id)
+	}
+	return filepath.Join(stateDir, "history", kind+"-"+id+".jsonl"), nil
+}
+
+// historyLine is one line in a history file.
+type historyLine struct {
+	Tstamp int64           `json:"tstamp"` // unix millis
+	Data   json.RawMessage `json:"data"`
+}
+
+// Append appends an entry to the history. Entries older
+// than historyRetention are pruned from time to time.
+func (h *History) Append(tstamp int64, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(historyLine{tstamp, data})
+	if err != nil {
+		return err
+	}
+	// prune rewrites the file, an append in between would get lost
+	unlock, err := lockState(h.filename)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := h.prune(tstamp); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(h.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// prune removes old entries, but only if the oldest entry is at
+// least one day beyond retention, so that prune rarely rewrites the file.
+func (h *History) prune(now int64) error {
+	minTstamp := now - historyRetention.Milliseconds()
+	first, err := h.firstTstamp()
+	if err != nil || first > minTstamp-(24*time.Hour).Milliseconds() {
+		return nil
+	}
+	lines, err := h.read(minTstamp, now)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, line := range lines {
+		data, err := json.Marshal(line)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return replaceFile(h.filename, buf.Bytes())
+}
+
+// firstTstamp reads the timestamp of the first history entry.
+func (h *History) firstTstamp() (int64, error) {
+	f, err := os.Open(h.filename)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	data, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil {
+		return 0, err
+	}
+	var line historyLine
+	if err := json.Unmarshal(data, &line); err != nil {
+		return 0, err
+	}
+	return line.Tstamp, nil
+}
+
+// read reads the history entries within [from,to].
+// A missing history file results in an empty list.
+func (h *History) read(from, to int64) ([]historyLine, error) {
+	f, err := os.Open(h.filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var lines []historyLine
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var line historyLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			continue // skip broken lines, e.g. from a crash while writing
+		}
+		if from <= line.Tstamp && line.Tstamp <= to {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// Samples reads machine samples within [from,to].
+func (h *History) Samples(from, to int64) ([]monibot.MachineSample, error) {
+	lines, err := h.read(from, to)
+	if err != nil {
+		return nil, err
+	}
+	samples := make([]monibot.MachineSample, 0, len(lines))
+	for _, line := range lines {
+		var sample monibot.MachineSample
+		if err := json.Unmarshal(line.Data, &sample); err != nil {
+			return nil, fmt.Errorf("cannot parse sample: %w", err)
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+// MetricValues reads metric values within [from,to].
+func (h *History) MetricValues(from, to int64) ([]MetricValue, error) {
+	lines, err := h.read(from, to)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]MetricValue, 0, len(lines))
+	for _, line := range lines {
+		var value MetricValue
+		if err := json.Unmarshal(line.Data, &value); err != nil {
+			return nil, fmt.Errorf("cannot parse metric value: %w", err)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// parseTime parses a point in time. It accepts absolute times
+// like "2025-01-04", "2025-01-04 10:00" and RFC3339, and
+// durations like "24h", which mean 24h before now.
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(strings.TrimPrefix(s, "-")); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+// history output formats
+const (
+	tableFormat = "table"
+	jsonFormat  = "json"
+	csvFormat   = "csv"
+)
+
+// fmtTstamp formats a unix millis timestamp for table output.
+func fmtTstamp(tstamp int64) string {
+	return time.UnixMilli(tstamp).Format("2006-01-02 15:04:05")
+}
+
+// printSamples prints machine samples in table, json or csv format.
+func printSamples(w io.Writer, samples []monibot.MachineSample, format string) error {
+	switch format {
+	case tableFormat:
+		fprtf(w, "%-19s | %6s | %6s | %6s | %3s | %3s | %4s | %12s | %12s | %12s | %12s", "Time", "Load1", "Load5", "Load15", "Cpu", "Mem", "Disk", "DiskRead", "DiskWrite", "NetRecv", "NetSend")
+		for _, s := range samples {
+			fprtf(w, "%-19s | %6.2f | %6.2f | %6.2f | %3d | %3d | %4d | %12d | %12d | %12d | %12d", fmtTstamp(s.Tstamp), s.Load1, s.Load5, s.Load15, s.CpuPercent, s.MemPercent, s.DiskPercent, s.DiskRead, s.DiskWrite, s.NetRecv, s.NetSend)
+		}
+		return nil
+	case jsonFormat:
+		type jsonSample struct {
+			Time        string  `json:"time"`
+			Load1       float64 `json:"load1"`
+			Load5       float64 `json:"load5"`
+			Load15      float64 `json:"load15"`
+			CpuPercent  int     `json:"cpuPercent"`
+			MemPercent  int     `json:"memPercent"`
+			DiskPercent int     `json:"diskPercent"`
+			DiskRead    int64   `json:"diskRead"`
+			DiskWrite   int64   `json:"diskWrite"`
+			NetRecv     int64   `json:"netRecv"`
+			NetSend     int64   `json:"netSend"`
+		}
+		list := make([]jsonSample, 0, len(samples))
+		for _, s := range samples {
+			list = append(list, jsonSample{time.UnixMilli(s.Tstamp).Format(time.RFC3339), s.Load1, s.Load5, s.Load15, s.CpuPercent, s.MemPercent, s.DiskPercent, s.DiskRead, s.DiskWrite, s.NetRecv, s.NetSend})
+		}
+		return printJson(w, list)
+	case csvFormat:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"Time", "Load1", "Load5", "Load15", "CpuPercent", "MemPercent", "DiskPercent", "DiskRead", "DiskWrite", "NetRecv", "NetSend"})
+		for _, s := range samples {
+			cw.Write([]string{
+				time.UnixMilli(s.Tstamp).Format(time.RFC3339),
+				fmtFloat(s.Load1), fmtFloat(s.Load5), fmtFloat(s.Load15),
+				strconv.Itoa(s.CpuPercent), strconv.Itoa(s.MemPercent), strconv.Itoa(s.DiskPercent),
+				fmtInt(s.DiskRead), fmtInt(s.DiskWrite), fmtInt(s.NetRecv), fmtInt(s.NetSend),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// printMetricValues prints metric values in table, json or csv format.
+func printMetricValues(w io.Writer, values []MetricValue, format string) error {
+	switch format {
+	case tableFormat:
+		fprtf(w, "%-19s | %s", "Time", "Value")
+		for _, v := range values {
+			fprtf(w, "%-19s | %d", fmtTstamp(v.Tstamp), v.Value)
+		}
+		return nil
+	case jsonFormat:
+		type jsonValue struct {
+			Time  string `json:"time"`
+			Value int64  `json:"value"`
+		}
+		list := make([]jsonValue, 0, len(values))
+		for _, v := range values {
+			list = append(list, jsonValue{time.UnixMilli(v.Tstamp).Format(time.RFC3339), v.Value})
+		}
+		return printJson(w, list)
+	case csvFormat:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"Time", "Value"})
+		for _, v := range values {
+			cw.Write([]string{time.UnixMilli(v.Tstamp).Format(time.RFC3339), fmtInt(v.Value)})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+func printJson(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func fmtFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func fmtInt(i int64) string {
+	return strconv.FormatInt(i, 10)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cvilsmeier/monibot-go"
+)
+
+func TestHistory(t *testing.T) {
+	dir := t.TempDir()
+	day := (24 * time.Hour).Milliseconds()
+	// samples
+	history := NewHistory(filepath.Join(dir, "history", "machine-m1.jsonl"))
+	samples, err := history.Samples(0, 1000)
+	assertNil(t, err)
+	assertEqual(t, 0, len(samples))
+	for i := int64(1); i <= 5; i++ {
+		err := history.Append(i*100, monibot.MachineSample{Tstamp: i * 100, CpuPercent: int(i)})
+		assertNil(t, err)
+	}
+	samples, err = history.Samples(200, 400)
+	assertNil(t, err)
+	assertEqual(t, 3, len(samples))
+	assertEqual(t, int64(200), samples[0].Tstamp)
+	assertEqual(t, 4, samples[2].CpuPercent)
+	// metric values
+	history = NewHistory(filepath.Join(dir, "history", "metric-x1.jsonl"))
+	assertNil(t, history.Append(10, MetricValue{10, 42}))
+	assertNil(t, history.Append(20, MetricValue{20, 43}))
+	values, err := history.MetricValues(0, 100)
+	assertNil(t, err)
+	assertEqual(t, 2, len(values))
+	assertEqual(t, MetricValue{20, 43}, values[1])
+	// old values are pruned
+	now := 100 * day
+	assertNil(t, history.Append(now, MetricValue{now, 44}))
+	values, err = history.MetricValues(0, now)
+	assertNil(t, err)
+	assertEqual(t, 1, len(values))
+	assertEqual(t, MetricValue{now, 44}, values[0])
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2025, 1, 4, 10, 0, 0, 0, time.UTC)
+	parse := func(s string) string {
+		t.Helper()
+		tm, err := parseTime(s, now)
+		if err != nil {
+			return err.Error()
+		}
+		return tm.UTC().Format(time.RFC3339)
+	}
+	assertEqual(t, "2025-01-03T10:00:00Z", parse("24h"))
+	assertEqual(t, "2025-01-04T09:30:00Z", parse("-30m"))
+	assertEqual(t, "2025-01-04T10:00:00Z", parse("0s"))
+	assertEqual(t, "2025-01-02T08:00:00Z", parse("2025-01-02T10:00:00+02:00"))
+	assertEqual(t, `invalid time "yesterday"`, parse("yesterday"))
+}
+
+func TestPrintMetricValues(t *testing.T) {
+	values := []MetricValue{{time.Date(2025, 1, 4, 10, 0, 0, 0, time.UTC).UnixMilli(), 42}}
+	var sb strings.Builder
+	assertNil(t, printMetricValues(&sb, values, csvFormat))
+	have := strings.ReplaceAll(sb.String(), time.UnixMilli(values[0].Tstamp).Format(time.RFC3339), "T")
+	assertEqual(t, "Time,Value\nT,42\n", have)
+	sb.Reset()
+	assertNil(t, printMetricValues(&sb, values, jsonFormat))
+	have = strings.ReplaceAll(sb.String(), time.UnixMilli(values[0].Tstamp).Format(time.RFC3339), "T")
+	assertEqual(t, "[\n  {\n    \"time\": \"T\",\n    \"value\": 42\n  }\n]\n", have)
+	assertEqual(t, `unknown format "xml"`, printMetricValues(&sb, values, "xml").Error())
+}
+
+func TestHistoryFilename(t *testing.T) {
+	filename, err := historyFilename("state", machineKind, "0123456789abcdef")
+	assertNil(t, err)
+	assertEqual(t, filepath.Join("state", "history", "machine-0123456789abcdef.jsonl"), filename)
+	_, err = historyFilename("state", metricKind, "../../etc/passwd")
+	assertEqual(t, `invalid metricId "../../etc/passwd"`, err.Error())
+	_, err = historyFilename("state", metricKind, "")
+	assertEqual(t, `invalid metricId ""`, err.Error())
+	_, err = historyFilename("state", machineKind, `a\b`)
+	assertEqual(t, `invalid machineId "a\\b"`, err.Error())
+}
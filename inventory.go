@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Inventory describes the hardware and software of a machine.
+type Inventory struct {
+	Hostname        string
+	OS              string
+	Platform        string
+	PlatformVersion string
+	KernelVersion   string
+	KernelArch      string
+	Uptime          time.Duration
+	CpuModel        string
+	CpuCores        int
+	CpuThreads      int
+	MemTotal        uint64
+	Filesystems     []InventoryFilesystem
+	Interfaces      []InventoryInterface
+	ListenPorts     []InventoryPort
+}
+
+// InventoryFilesystem is a mounted filesystem.
+type InventoryFilesystem struct {
+	Device      string
+	Mountpoint  string
+	Fstype      string
+	Total       uint64
+	Used        uint64
+	UsedPercent float64
+}
+
+// InventoryInterface is a network interface.
+type InventoryInterface struct {
+	Name         string
+	HardwareAddr string
+	Addrs        []string
+}
+
+// InventoryPort is a listening TCP port.
+type InventoryPort struct {
+	Addr string
+	Port uint32
+	Pid  int32
+}
+
+// formatInventory formats an inventory as machine text.
+func formatInventory(inv Inventory) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "== System ==\n")
+	fmt.Fprintf(&sb, "hostname:  %s\n", inv.Hostname)
+	fmt.Fprintf(&sb, "os:        %s\n", strings.TrimSpace(inv.OS+" "+inv.Platform+" "+inv.PlatformVersion))
+	fmt.Fprintf(&sb, "kernel:    %s (%s)\n", inv.KernelVersion, inv.KernelArch)
+	fmt.Fprintf(&sb, "uptime:    %s\n", fmtUptime(inv.Uptime))
+	fmt.Fprintf(&sb, "\n== CPU ==\n")
+	fmt.Fprintf(&sb, "model:     %s\n", inv.CpuModel)
+	fmt.Fprintf(&sb, "cores:     %d (%d threads)\n", inv.CpuCores, inv.CpuThreads)
+	fmt.Fprintf(&sb, "\n== Memory ==\n")
+	fmt.Fprintf(&sb, "total:     %s\n", fmtBytes(inv.MemTotal))
+	fmt.Fprintf(&sb, "\n== Filesystems ==\n")
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Device\tMountpoint\tType\tSize\tUsed\tUse%%\n")
+	for _, fs := range inv.Filesystems {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%.0f%%\n", fs.Device, fs.Mountpoint, fs.Fstype, fmtBytes(fs.Total), fmtBytes(fs.Used), fs.UsedPercent)
+	}
+	tw.Flush()
+	fmt.Fprintf(&sb, "\n== Network Interfaces ==\n")
+	tw = tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Name\tHardwareAddr\tAddrs\n")
+	for _, iface := range inv.Interfaces {
+		hardwareAddr := iface.HardwareAddr
+		if hardwareAddr == "" {
+			hardwareAddr = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", iface.Name, hardwareAddr, strings.Join(iface.Addrs, ", "))
+	}
+	tw.Flush()
+	fmt.Fprintf(&sb, "\n== Listening TCP Ports ==\n")
+	tw = tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Addr\tPort\tPid\n")
+	for _, port := range inv.ListenPorts {
+		fmt.Fprintf(tw, "%s\t%d\t%d\n", port.Addr, port.Port, port.Pid)
+	}
+	tw.Flush()
+	return sb.String()
+}
+
+// fmtUptime formats an uptime like "12d 3h 4m".
+func fmtUptime(d time.Duration) string {
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	minutes := int(d.Minutes()) % 60
+	return fmt.Sprintf("%dd %dh %dm", days, hours, minutes)
+}
+
+// fmtBytes formats a byte count like "1.5 GiB".
+func fmtBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
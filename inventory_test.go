@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatInventory(t *testing.T) {
+	inv := Inventory{
+		Hostname:        "db1",
+		OS:              "linux",
+		Platform:        "ubuntu",
+		PlatformVersion: "24.04",
+		KernelVersion:   "6.8.0-51-generic",
+		KernelArch:      "x86_64",
+		Uptime:          12*24*time.Hour + 3*time.Hour + 4*time.Minute + 5*time.Second,
+		CpuModel:        "AMD EPYC",
+		CpuCores:        4,
+		CpuThreads:      8,
+		MemTotal:        16 * 1024 * 1024 * 1024,
+		Filesystems: []InventoryFilesystem{
+			{"/dev/sda1", "/", "ext4", 100 * 1024 * 1024 * 1024, 27 * 1024 * 1024 * 1024, 27},
+		},
+		Interfaces: []InventoryInterface{
+			{"lo", "", []string{"127.0.0.1/8"}},
+			{"eth0", "00:11:22:33:44:55", []string{"10.0.0.5/24", "fe80::1/64"}},
+		},
+		ListenPorts: []InventoryPort{
+			{"0.0.0.0", 22, 812},
+			{"127.0.0.1", 5432, 1013},
+		},
+	}
+	assertEqual(t, `== System ==
+hostname:  db1
+os:        linux ubuntu 24.04
+kernel:    6.8.0-51-generic (x86_64)
+uptime:    12d 3h 4m
+
+== CPU ==
+model:     AMD EPYC
+cores:     4 (8 threads)
+
+== Memory ==
+total:     16.0 GiB
+
+== Filesystems ==
+Device     Mountpoint  Type  Size       Used      Use%
+/dev/sda1  /           ext4  100.0 GiB  27.0 GiB  27%
+
+== Network Interfaces ==
+Name  HardwareAddr       Addrs
+lo    -                  127.0.0.1/8
+eth0  00:11:22:33:44:55  10.0.0.5/24, fe80::1/64
+
+== Listening TCP Ports ==
+Addr       Port  Pid
+0.0.0.0    22    812
+127.0.0.1  5432  1013
+`, formatInventory(inv))
+}
+
+func TestFmtBytes(t *testing.T) {
+	assertEqual(t, "0 B", fmtBytes(0))
+	assertEqual(t, "1023 B", fmtBytes(1023))
+	assertEqual(t, "1.0 KiB", fmtBytes(1024))
+	assertEqual(t, "1.5 MiB", fmtBytes(1536*1024))
+	assertEqual(t, "2.0 TiB", fmtBytes(2*1024*1024*1024*1024))
+}
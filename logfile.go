@@ -0,0 +1,234 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// log file defaults
+const (
+	defaultLogMaxSize    = 10 * 1024 * 1024
+	defaultLogMaxBackups = 5
+	logBackupTimeFormat  = "20060102-150405.000"
+)
+
+// LogFile is an io.Writer that appends to a file. It rotates the file
+// when it gets bigger than maxSize bytes, or older than maxAge, counted
+// from when the file was created, not when moni opened it. Rotated
+// files are renamed to 'filename.<timestamp>', optionally compressed
+// with gzip, and at most maxBackups rotated files are kept. A zero
+// maxSize, maxAge or maxBackups means no limit. It is safe for
+// concurrent use.
+type LogFile struct {
+	filename   string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	compress   bool
+	now        func() time.Time
+	mu         sync.Mutex
+	file       *os.File
+	size       int64
+	info       os.FileInfo
+	created    time.Time
+}
+
+// OpenLogFile opens a LogFile.
+func OpenLogFile(filename string, maxSize int64, maxAge time.Duration, maxBackups int, compress bool) (*LogFile, error) {
+	f := &LogFile{
+		filename:   filename,
+		maxSize:    maxSize,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+		compress:   compress,
+		now:        time.Now,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *LogFile) open() error {
+	file, err := os.OpenFile(f.filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	// the age counts from when the file was created: a reopened file
+	// keeps its age, an existing file takes its creation time, and an
+	// empty file starts now, even if the file system reuses the creation
+	// time of a rotated file with the same name
+	switch {
+	case f.info != nil && os.SameFile(f.info, info):
+	case f.size > 0:
+		f.created = fileCreated(f.filename, info)
+	default:
+		f.created = f.now()
+	}
+	f.info = info
+	return nil
+}
+
+// Write writes p to the file, rotating it before if needed.
+func (f *LogFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	tooBig := f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize
+	tooOld := f.maxAge > 0 && f.size > 0 && f.now().Sub(f.created) >= f.maxAge
+	if tooBig || tooOld {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Reopen closes and reopens the file, e.g. after it was
+// rotated by an external tool like logrotate.
+func (f *LogFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.close()
+	return f.open()
+}
+
+// ReopenOnSignal reopens the file whenever moni receives SIGHUP.
+func (f *LogFile) ReopenOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			if err := f.Reopen(); err != nil {
+				slog.Warn("cannot reopen log file", "file", f.filename, "error", err)
+			}
+		}
+	}()
+}
+
+// Close closes the file.
+func (f *LogFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.close()
+}
+
+func (f *LogFile) close() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// rotate renames the current file to a backup file and opens a new one.
+func (f *LogFile) rotate() error {
+	f.close()
+	backup := f.filename + "." + f.now().Format(logBackupTimeFormat)
+	if err := os.Rename(f.filename, backup); err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	// compress and prune are best effort, we cannot log their errors
+	if f.compress {
+		gzipFile(backup)
+	}
+	f.prune()
+	return nil
+}
+
+// prune removes the oldest backup files, so that at most maxBackups remain.
+func (f *LogFile) prune() error {
+	if f.maxBackups <= 0 {
+		return nil
+	}
+	backups, err := f.backups()
+	if err != nil {
+		return err
+	}
+	for len(backups) > f.maxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// backups returns the backup files, oldest first.
+func (f *LogFile) backups() ([]string, error) {
+	dir, base := filepath.Split(f.filename)
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, entry := range entries {
+		suffix, ok := strings.CutPrefix(entry.Name(), base+".")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if _, err := time.Parse(logBackupTimeFormat, strings.TrimSuffix(suffix, ".gz")); err != nil {
+			continue
+		}
+		backups = append(backups, filepath.Join(dir, entry.Name()))
+	}
+	// timestamps sort lexically
+	slices.Sort(backups)
+	return backups, nil
+}
+
+// gzipFile compresses filename to filename.gz and removes filename.
+func gzipFile(filename string) error {
+	src, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(filename+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0640)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		os.Remove(filename + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(filename + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(filename + ".gz")
+		return err
+	}
+	src.Close()
+	return os.Remove(filename)
+}
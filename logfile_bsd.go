@@ -0,0 +1,18 @@
+//go:build darwin || freebsd || netbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// fileCreated returns the creation time of a file, or its modification
+// time if the file system does not record creation times.
+func fileCreated(filename string, info os.FileInfo) time.Time {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && st.Birthtimespec.Sec > 0 {
+		return time.Unix(st.Birthtimespec.Unix())
+	}
+	return info.ModTime()
+}
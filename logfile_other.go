@@ -0,0 +1,14 @@
+//go:build !linux && !windows && !darwin && !freebsd && !netbsd
+
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// fileCreated returns the modification time of a file, since the
+// creation time is not known on this platform.
+func fileCreated(filename string, info os.FileInfo) time.Time {
+	return info.ModTime()
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "moni.log")
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	f, err := OpenLogFile(filename, 10, 0, 2, true)
+	assertNil(t, err)
+	defer f.Close()
+	f.now = func() time.Time { return now }
+	write := func(s string) {
+		_, err := io.WriteString(f, s)
+		assertNil(t, err)
+	}
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		assertNil(t, err)
+		return string(data)
+	}
+	write("line1\n")
+	write("line2\n") // rotates before write
+	assertEqual(t, "line2\n", read("moni.log"))
+	// rotated file is compressed
+	gz, err := os.Open(filepath.Join(dir, "moni.log.20240501-120000.000.gz"))
+	assertNil(t, err)
+	zr, err := gzip.NewReader(gz)
+	assertNil(t, err)
+	data, err := io.ReadAll(zr)
+	assertNil(t, err)
+	gz.Close()
+	assertEqual(t, "line1\n", string(data))
+	// max backups
+	for i := range 3 {
+		now = now.Add(time.Second)
+		write(strings.Repeat("x", 9+i))
+	}
+	backups, err := f.backups()
+	assertNil(t, err)
+	assertEqual(t, 2, len(backups))
+	assertEqual(t, filepath.Join(dir, "moni.log.20240501-120002.000.gz"), backups[0])
+	assertEqual(t, filepath.Join(dir, "moni.log.20240501-120003.000.gz"), backups[1])
+	assertEqual(t, strings.Repeat("x", 11), read("moni.log"))
+	entries, err := os.ReadDir(dir)
+	assertNil(t, err)
+	assertEqual(t, 3, len(entries))
+}
+
+func TestLogFileMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "moni.log")
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	f, err := OpenLogFile(filename, 0, time.Hour, 0, false)
+	assertNil(t, err)
+	defer f.Close()
+	f.now = func() time.Time { return now }
+	f.created = now
+	io.WriteString(f, "a\n")
+	now = now.Add(59 * time.Minute)
+	io.WriteString(f, "b\n")
+	now = now.Add(time.Minute)
+	io.WriteString(f, "c\n")
+	data, err := os.ReadFile(filepath.Join(dir, "moni.log.20240501-130000.000"))
+	assertNil(t, err)
+	assertEqual(t, "a\nb\n", string(data))
+	data, err = os.ReadFile(filename)
+	assertNil(t, err)
+	assertEqual(t, "c\n", string(data))
+}
+
+func TestLogFileReopen(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("cannot rename open files")
+	}
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "moni.log")
+	f, err := OpenLogFile(filename, 0, 0, 0, false)
+	assertNil(t, err)
+	defer f.Close()
+	io.WriteString(f, "a\n")
+	// external rotation
+	assertNil(t, os.Rename(filename, filename+".1"))
+	io.WriteString(f, "b\n")
+	assertNil(t, f.Reopen())
+	io.WriteString(f, "c\n")
+	data, err := os.ReadFile(filename + ".1")
+	assertNil(t, err)
+	assertEqual(t, "a\nb\n", string(data))
+	data, err = os.ReadFile(filename)
+	assertNil(t, err)
+	assertEqual(t, "c\n", string(data))
+}
+
+func TestLogFileAgeAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "moni.log")
+	assertNil(t, os.WriteFile(filename, []byte("a\n"), 0640))
+	// moni restarts a day after the file was created
+	now := time.Now().Add(24 * time.Hour)
+	f, err := OpenLogFile(filename, 0, 2*time.Hour, 0, false)
+	assertNil(t, err)
+	defer f.Close()
+	f.now = func() time.Time { return now }
+	assertNil(t, f.Reopen())
+	io.WriteString(f, "b\n")
+	data, err := os.ReadFile(filename)
+	assertNil(t, err)
+	assertEqual(t, "b\n", string(data))
+	backups, err := f.backups()
+	assertNil(t, err)
+	assertEqual(t, 1, len(backups))
+	// the new file starts now, and keeps its age when reopened
+	now = now.Add(time.Hour)
+	assertNil(t, f.Reopen())
+	io.WriteString(f, "c\n")
+	now = now.Add(time.Hour)
+	assertNil(t, f.Reopen())
+	io.WriteString(f, "d\n")
+	data, err = os.ReadFile(filename)
+	assertNil(t, err)
+	assertEqual(t, "d\n", string(data))
+	backups, err = f.backups()
+	assertNil(t, err)
+	assertEqual(t, 2, len(backups))
+}
+
+func TestLogFilePerm(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no unix file modes")
+	}
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "moni.log")
+	f, err := OpenLogFile(filename, 5, 0, 0, true)
+	assertNil(t, err)
+	defer f.Close()
+	io.WriteString(f, "line1\n")
+	io.WriteString(f, "line2\n")
+	backups, err := f.backups()
+	assertNil(t, err)
+	assertEqual(t, 1, len(backups))
+	for _, name := range []string{filename, backups[0]} {
+		info, err := os.Stat(name)
+		assertNil(t, err)
+		assertEqual(t, os.FileMode(0), info.Mode().Perm()&0007)
+	}
+}
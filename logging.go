@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// log formats
+const (
+	logFormatText = "text"
+	logFormatJson = "json"
+)
+
+// parseLogLevel parses a log level: debug, info, warn or error.
+func parseLogLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("invalid log level %q, must be debug, info, warn or error", s)
+}
+
+// newLogger creates a logger that writes to w in format text or json.
+func newLogger(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	switch format {
+	case logFormatText:
+		return slog.New(newTextHandler(w, level)), nil
+	case logFormatJson:
+		return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q, must be %s or %s", format, logFormatText, logFormatJson)
+}
+
+// textHandler is a slog.Handler that writes lines like
+// "2024/05/01 12:00:00 WARNING: cannot send heartbeat watchdogId=42 error=timeout",
+// the format moni has always used.
+type textHandler struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	level  slog.Level
+	prefix string // group prefix for keys
+	attrs  string // preformatted attrs
+}
+
+func newTextHandler(w io.Writer, level slog.Level) *textHandler {
+	return &textHandler{mu: &sync.Mutex{}, w: w, level: level}
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var sb strings.Builder
+	if !r.Time.IsZero() {
+		sb.WriteString(r.Time.Format("2006/01/02 15:04:05 "))
+	}
+	sb.WriteString(levelName(r.Level))
+	sb.WriteString(": ")
+	sb.WriteString(r.Message)
+	sb.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		writeAttr(&sb, h.prefix, a)
+		return true
+	})
+	sb.WriteString("\n")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, sb.String())
+	return err
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var sb strings.Builder
+	for _, a := range attrs {
+		writeAttr(&sb, h.prefix, a)
+	}
+	h2 := *h
+	h2.attrs += sb.String()
+	return &h2
+}
+
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix += name + "."
+	return &h2
+}
+
+// levelName returns the name of a level, as moni has always used it.
+func levelName(level slog.Level) string {
+	switch {
+	case level < slog.LevelInfo:
+		return "DEBUG"
+	case level < slog.LevelWarn:
+		return "INFO"
+	case level < slog.LevelError:
+		return "WARNING"
+	}
+	return "ERROR"
+}
+
+func writeAttr(sb *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			writeAttr(sb, prefix, ga)
+		}
+		return
+	}
+	value := a.Value.String()
+	if value == "" || strings.IndexFunc(value, func(r rune) bool { return unicode.IsSpace(r) || r == '"' || r == '=' }) >= 0 {
+		value = strconv.Quote(value)
+	}
+	fmt.Fprintf(sb, " %s%s=%s", prefix, a.Key, value)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	level, err := parseLogLevel("debug")
+	assertNil(t, err)
+	assertEqual(t, slog.LevelDebug, level)
+	level, err = parseLogLevel("WARNING")
+	assertNil(t, err)
+	assertEqual(t, slog.LevelWarn, level)
+	_, err = parseLogLevel("trace")
+	assertEqual(t, `invalid log level "trace", must be debug, info, warn or error`, err.Error())
+}
+
+func TestTextLogger(t *testing.T) {
+	var sb strings.Builder
+	logger, err := newLogger(&sb, logFormatText, slog.LevelInfo)
+	assertNil(t, err)
+	logger = logger.With("command", "sample")
+	logger.Debug("not logged")
+	logger.Info("will send samples in background", "machineId", "42", "interval", "5m")
+	logger.Warn("cannot send sample", "error", fmt.Errorf("connection refused"))
+	logger.WithGroup("api").Error("failed", "attempt", 3, "url", "")
+	lines := strings.Split(strings.TrimSuffix(sb.String(), "\n"), "\n")
+	assertEqual(t, 3, len(lines))
+	// cut off timestamp "2024/05/01 12:00:00 "
+	assertEqual(t, "INFO: will send samples in background command=sample machineId=42 interval=5m", lines[0][20:])
+	assertEqual(t, `WARNING: cannot send sample command=sample error="connection refused"`, lines[1][20:])
+	assertEqual(t, `ERROR: failed command=sample api.attempt=3 api.url=""`, lines[2][20:])
+}
+
+func TestJsonLogger(t *testing.T) {
+	var sb strings.Builder
+	logger, err := newLogger(&sb, logFormatJson, slog.LevelDebug)
+	assertNil(t, err)
+	logger.With("command", "heartbeat").Debug("sent", "watchdogId", "7")
+	var m map[string]any
+	assertNil(t, json.Unmarshal([]byte(sb.String()), &m))
+	assertEqual(t, "DEBUG", m["level"])
+	assertEqual(t, "sent", m["msg"])
+	assertEqual(t, "heartbeat", m["command"])
+	assertEqual(t, "7", m["watchdogId"])
+	_, err = newLogger(&sb, "xml", slog.LevelDebug)
+	assertEqual(t, `invalid log format "xml", must be text or json`, err.Error())
+}
+
+func TestApiLogger(t *testing.T) {
+	saved := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(saved) })
+	var sb strings.Builder
+	logger, err := newLogger(&sb, logFormatJson, slog.LevelDebug)
+	assertNil(t, err)
+	slog.SetDefault(logger)
+	(&ApiLogger{}).Debug("GET %s", "/api/ping")
+	var m map[string]any
+	assertNil(t, json.Unmarshal([]byte(sb.String()), &m))
+	assertEqual(t, "GET /api/ping", m["msg"])
+	assertEqual(t, "api", m["component"])
+	assertEqual(t, nil, m[slog.SourceKey])
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestMain runs main instead of the tests if the test binary is
+// started by runMoni.
+func TestMain(m *testing.M) {
+	if os.Getenv("MONI_TEST_MAIN") == "1" {
+		os.Args = append([]string{"moni"}, strings.Fields(os.Getenv("MONI_TEST_ARGS"))...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runMoni runs moni with args in a child process. It returns the
+// combined stdout and stderr output, and the exit code.
+func runMoni(t *testing.T, args string, env ...string) (string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "MONI_TEST_MAIN=1", "MONI_TEST_ARGS="+args)
+	cmd.Env = append(cmd.Env, env...)
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return string(out), exitErr.ExitCode()
+	}
+	assertNil(t, err)
+	return string(out), 0
+}
+
+func TestValueBeforeName(t *testing.T) {
+	env := []string{apiKeyEnvKey + "=0123456789abcdef", urlEnvKey + "=http://127.0.0.1:1", "MONIBOT_STATE_DIR=" + t.TempDir()}
+	for _, args := range []string{
+		"-trials 1 inc name:Logins -5",
+		"-trials 1 set name:Logins 12x",
+		"-trials 1 values name:Logins 1,-2",
+	} {
+		out, exitCode := runMoni(t, args, env...)
+		assertEqual(t, 2, exitCode)
+		if !strings.HasPrefix(out, "cannot parse value") {
+			t.Fatalf("moni %s: want value error, have %q", args, out)
+		}
+	}
+}
+
+func TestMaxDelayDefault(t *testing.T) {
+	env := []string{apiKeyEnvKey + "=0123456789abcdef", urlEnvKey + "=http://127.0.0.1:1", "MONIBOT_STATE_DIR=" + t.TempDir()}
+	out, exitCode := runMoni(t, "config", env...)
+	assertEqual(t, 0, exitCode)
+	assertEqual(t, true, strings.Contains(out, "maxDelay        1m\n"))
+	// a long delay raises the default maxDelay
+	out, exitCode = runMoni(t, "-delay 2m config", env...)
+	assertEqual(t, 0, exitCode)
+	assertEqual(t, true, strings.Contains(out, "maxDelay        2m\n"))
+	out, exitCode = runMoni(t, "-delay 2m -trials 1 ping", env...)
+	assertEqual(t, 1, exitCode)
+	assertEqual(t, false, strings.Contains(out, "invalid maxDelay"))
+	// an explicit maxDelay must not be below delay
+	out, exitCode = runMoni(t, "-delay 2m -maxDelay 1m -trials 1 ping", env...)
+	assertEqual(t, 2, exitCode)
+	assertEqual(t, true, strings.HasPrefix(out, "invalid maxDelay 1m, must be >= delay 2m"))
+}
+
+func TestApiKeyFlagWinsOverEnv(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "apikey")
+	assertNil(t, os.WriteFile(keyFile, []byte("fromfile0123456789\n"), 0600))
+	out, exitCode := runMoni(t, "-showSecrets -apiKeyFile "+keyFile+" config", apiKeyEnvKey+"=fromenv0123456789")
+	assertEqual(t, 0, exitCode)
+	assertEqual(t, true, strings.Contains(out, "apiKey          fromfile0123456789 (from file "+keyFile+")"))
+}
+
+// TestTextRecordsEverySend checks that a plain 'moni text' records the
+// text, so a later -ifChanged compares against what the machine shows.
+func TestTextRecordsEverySend(t *testing.T) {
+	dir := t.TempDir()
+	env := []string{apiKeyEnvKey + "=0123456789abcdef", urlEnvKey + "=http://127.0.0.1:1", "MONIBOT_STATE_DIR=" + dir}
+	textFile := filepath.Join(dir, "text.txt")
+	assertNil(t, os.WriteFile(textFile, []byte("first"), 0600))
+	_, exitCode := runMoni(t, "-trials 1 text 42 "+textFile+" -ifChanged", env...)
+	assertEqual(t, 0, exitCode)
+	assertNil(t, os.WriteFile(textFile, []byte("second"), 0600))
+	_, exitCode = runMoni(t, "-trials 1 text 42 "+textFile, env...)
+	assertEqual(t, 0, exitCode)
+	text, found := NewTextLog(filepath.Join(dir, "texts")).Load("42")
+	assertEqual(t, true, found)
+	assertEqual(t, "second", text)
+}
+
+// TestStopTime stops 'moni time' with SIGTERM and checks that moni
+// exits with the exit code of its command, and removes the pid file.
+func TestStopTime(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh and SIGTERM")
+	}
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "moni.pid")
+	script := filepath.Join(dir, "script.sh")
+	assertNil(t, os.WriteFile(script, []byte("trap 'exit 5' TERM\nsleep 5 &\nwait\n"), 0600))
+	env := []string{apiKeyEnvKey + "=0123456789abcdef", urlEnvKey + "=http://127.0.0.1:1", "MONIBOT_STATE_DIR=" + dir}
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "MONI_TEST_MAIN=1", "MONI_TEST_ARGS=-trials 1 -pidFile "+pidFile+" time 42 -- sh "+script)
+	cmd.Env = append(cmd.Env, env...)
+	assertNil(t, cmd.Start())
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(50 * time.Millisecond) {
+		if _, running, _ := readPidFile(pidFile); running {
+			break
+		}
+	}
+	time.Sleep(200 * time.Millisecond)
+	assertNil(t, cmd.Process.Signal(syscall.SIGTERM))
+	err := cmd.Wait()
+	var exitErr *exec.ExitError
+	assertEqual(t, true, errors.As(err, &exitErr))
+	assertEqual(t, 5, exitErr.ExitCode())
+	_, err = os.Stat(pidFile)
+	assertEqual(t, true, os.IsNotExist(err))
+	// a failed command removes the pid file, too
+	_, exitCode := runMoni(t, "-trials 1 -pidFile "+pidFile+" ping", env...)
+	assertEqual(t, 1, exitCode)
+	_, err = os.Stat(pidFile)
+	assertEqual(t, true, os.IsNotExist(err))
+}
+
+func TestCommandArgs(t *testing.T) {
+	fs := flag.NewFlagSet("moni", flag.ContinueOnError)
+	fs.String("url", "", "")
+	fs.Bool("v", false, "")
+	args := func(s string) string { return strings.Join(commandArgs(fs, strings.Fields(s)), " ") }
+	assertEqual(t, "check watchdog 42", args("check watchdog 42"))
+	assertEqual(t, "check watchdog 42", args("-v -url http://x check watchdog 42"))
+	assertEqual(t, "check watchdog", args("--url=http://x -v=true check watchdog"))
+	assertEqual(t, "check", args("-unknown check"))
+	assertEqual(t, "-v check", args("-- -v check"))
+	assertEqual(t, "", args("-url"))
+}
+
+func TestCheckErrorsAreUnknown(t *testing.T) {
+	env := []string{apiKeyEnvKey + "=", urlEnvKey + "=http://127.0.0.1:1", "MONIBOT_STATE_DIR=" + t.TempDir()}
+	for _, tc := range []struct {
+		args string
+		env  string
+		want string
+	}{
+		{"check watchdog 42 -local", "", "WATCHDOG UNKNOWN - empty apiKey"},
+		{"-trials 0 check machine 42", apiKeyEnvKey + "=0123456789abcdef", "MACHINE UNKNOWN - invalid trials 0, must be >= 1"},
+		{"-nope check metric 42", "", "METRIC UNKNOWN - flag provided but not defined: -nope"},
+		{"check metric 42", "MONIBOT_TRIALS=x", `METRIC UNKNOWN - cannot parse trials "x": strconv.Atoi: parsing "x": invalid syntax`},
+	} {
+		out, exitCode := runMoni(t, tc.args, append(env, tc.env)...)
+		assertEqual(t, checkUnknown, exitCode)
+		assertEqual(t, tc.want, strings.TrimSpace(out))
+	}
+}
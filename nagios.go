@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cvilsmeier/monibot-go"
+)
+
+// Nagios/Icinga plugin exit codes
+const (
+	checkOk       = 0
+	checkWarning  = 1
+	checkCritical = 2
+	checkUnknown  = 3
+)
+
+// defaultCheckMaxAge is the default max. age of a machine sample.
+const defaultCheckMaxAge = 3 * minSampleInterval
+
+// CheckResult is the result of a Nagios/Icinga check.
+type CheckResult struct {
+	Service  string   // "WATCHDOG", "MACHINE", "METRIC"
+	Code     int      // checkOk, checkWarning, checkCritical, checkUnknown
+	Text     string   // human readable text
+	Perfdata []string // "label=value[UOM];[warn];[crit];[min];[max]"
+}
+
+// String formats a check result as Nagios plugin output line.
+func (r CheckResult) String() string {
+	s := fmt.Sprintf("%s %s - %s", r.Service, checkCodeName(r.Code), r.Text)
+	if len(r.Perfdata) > 0 {
+		s += " | " + strings.Join(r.Perfdata, " ")
+	}
+	return s
+}
+
+func checkCodeName(code int) string {
+	switch code {
+	case checkOk:
+		return "OK"
+	case checkWarning:
+		return "WARNING"
+	case checkCritical:
+		return "CRITICAL"
+	}
+	return "UNKNOWN"
+}
+
+// checkNoLocal is the result of a check without -local. The Monibot API
+// does not provide heartbeat times, samples or metric values, so moni
+// can check them only from the state it recorded on this host.
+func checkNoLocal(service, name, what string) CheckResult {
+	return CheckResult{
+		Service: service,
+		Code:    checkUnknown,
+		Text:    fmt.Sprintf("%s: the Monibot API does not provide %s, use -local to check the %s sent from this host", name, what, what),
+	}
+}
+
+// checkWatchdog checks that a watchdog is not overdue.
+func checkWatchdog(status WatchdogStatus, now int64) CheckResult {
+	watchdog := status.Watchdog
+	r := CheckResult{Service: "WATCHDOG"}
+	if status.LastHeartbeat == 0 {
+		r.Code = checkUnknown
+		r.Text = fmt.Sprintf("%s: no heartbeat sent from this host", watchdog.Name)
+		return r
+	}
+	age := time.Duration(now-status.LastHeartbeat) * time.Millisecond
+	r.Text = fmt.Sprintf("%s: last heartbeat %s ago", watchdog.Name, fmtDuration(age.Round(time.Second)))
+	r.Perfdata = []string{fmt.Sprintf("age=%ds;;%d;0", int64(age.Seconds()), watchdog.IntervalMillis/1000)}
+	if status.IsOverdue() {
+		r.Code = checkCritical
+		r.Text += fmt.Sprintf(", overdue %s", fmtDuration(status.Overdue.Round(time.Second)))
+	}
+	return r
+}
+
+// MachineLimits holds critical thresholds for machine checks.
+// A zero threshold is not checked.
+type MachineLimits struct {
+	CpuPercent  int
+	MemPercent  int
+	DiskPercent int
+	MaxAge      time.Duration
+}
+
+// checkMachine checks the last sample of a machine against limits.
+func checkMachine(machine monibot.Machine, sample monibot.MachineSample, found bool, now int64, limits MachineLimits) CheckResult {
+	r := CheckResult{Service: "MACHINE"}
+	if !found {
+		r.Code = checkUnknown
+		r.Text = fmt.Sprintf("%s: no sample sent from this host", machine.Name)
+		return r
+	}
+	age := time.Duration(now-sample.Tstamp) * time.Millisecond
+	if limits.MaxAge > 0 && age > limits.MaxAge {
+		r.Code = checkUnknown
+		r.Text = fmt.Sprintf("%s: last sample is %s old", machine.Name, fmtDuration(age.Round(time.Second)))
+		return r
+	}
+	var exceeded []string
+	check := func(name string, value, limit int) {
+		if limit > 0 && value > limit {
+			exceeded = append(exceeded, fmt.Sprintf("%s %d%% > %d%%", name, value, limit))
+		}
+		limitStr := ""
+		if limit > 0 {
+			limitStr = fmt.Sprint(limit)
+		}
+		r.Perfdata = append(r.Perfdata, fmt.Sprintf("%s=%d%%;;%s;0;100", name, value, limitStr))
+	}
+	check("cpu", sample.CpuPercent, limits.CpuPercent)
+	check("mem", sample.MemPercent, limits.MemPercent)
+	check("disk", sample.DiskPercent, limits.DiskPercent)
+	r.Perfdata = append(r.Perfdata,
+		fmt.Sprintf("load1=%.2f;;;0", sample.Load1),
+		fmt.Sprintf("load5=%.2f;;;0", sample.Load5),
+		fmt.Sprintf("load15=%.2f;;;0", sample.Load15),
+	)
+	if len(exceeded) > 0 {
+		r.Code = checkCritical
+		r.Text = fmt.Sprintf("%s: %s", machine.Name, strings.Join(exceeded, ", "))
+		return r
+	}
+	r.Text = fmt.Sprintf("%s: cpu %d%%, mem %d%%, disk %d%%", machine.Name, sample.CpuPercent, sample.MemPercent, sample.DiskPercent)
+	return r
+}
+
+// checkMetric checks the last value of a metric against warn and crit thresholds.
+// A negative threshold is not checked.
+func checkMetric(metric monibot.Metric, value MetricValue, found bool, warn, crit int64) CheckResult {
+	r := CheckResult{Service: "METRIC"}
+	if !found {
+		r.Code = checkUnknown
+		r.Text = fmt.Sprintf("%s: no value sent from this host", metric.Name)
+		return r
+	}
+	thresholdStr := func(t int64) string {
+		if t < 0 {
+			return ""
+		}
+		return fmt.Sprint(t)
+	}
+	r.Perfdata = []string{fmt.Sprintf("value=%d;%s;%s;0", value.Value, thresholdStr(warn), thresholdStr(crit))}
+	switch {
+	case crit >= 0 && value.Value > crit:
+		r.Code = checkCritical
+		r.Text = fmt.Sprintf("%s: value %d > %d", metric.Name, value.Value, crit)
+	case warn >= 0 && value.Value > warn:
+		r.Code = checkWarning
+		r.Text = fmt.Sprintf("%s: value %d > %d", metric.Name, value.Value, warn)
+	default:
+		r.Text = fmt.Sprintf("%s: value %d", metric.Name, value.Value)
+	}
+	return r
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cvilsmeier/monibot-go"
+)
+
+func TestCheckWatchdog(t *testing.T) {
+	watchdog := monibot.Watchdog{Id: "w1", Name: "Backup", IntervalMillis: 3600_000}
+	now := time.Date(2025, 1, 4, 10, 0, 0, 0, time.UTC).UnixMilli()
+	// ok
+	status := watchdogStatuses([]monibot.Watchdog{watchdog}, map[string]int64{"w1": now - 120_000}, now)[0]
+	result := checkWatchdog(status, now)
+	assertEqual(t, checkOk, result.Code)
+	assertEqual(t, "WATCHDOG OK - Backup: last heartbeat 2m ago | age=120s;;3600;0", result.String())
+	// overdue
+	status = watchdogStatuses([]monibot.Watchdog{watchdog}, map[string]int64{"w1": now - 3900_000}, now)[0]
+	result = checkWatchdog(status, now)
+	assertEqual(t, checkCritical, result.Code)
+	assertEqual(t, "WATCHDOG CRITICAL - Backup: last heartbeat 1h5m ago, overdue 5m | age=3900s;;3600;0", result.String())
+	// unknown
+	status = watchdogStatuses([]monibot.Watchdog{watchdog}, nil, now)[0]
+	result = checkWatchdog(status, now)
+	assertEqual(t, checkUnknown, result.Code)
+	assertEqual(t, "WATCHDOG UNKNOWN - Backup: no heartbeat sent from this host", result.String())
+}
+
+func TestCheckMachine(t *testing.T) {
+	machine := monibot.Machine{Id: "m1", Name: "db"}
+	now := time.Date(2025, 1, 4, 10, 0, 0, 0, time.UTC).UnixMilli()
+	sample := monibot.MachineSample{Tstamp: now - 60_000, CpuPercent: 13, MemPercent: 95, DiskPercent: 76, Load1: .5, Load5: .6, Load15: .7}
+	limits := MachineLimits{CpuPercent: 90, MemPercent: 90, MaxAge: 15 * time.Minute}
+	// critical
+	result := checkMachine(machine, sample, true, now, limits)
+	assertEqual(t, checkCritical, result.Code)
+	assertEqual(t, "MACHINE CRITICAL - db: mem 95% > 90% | cpu=13%;;90;0;100 mem=95%;;90;0;100 disk=76%;;;0;100 load1=0.50;;;0 load5=0.60;;;0 load15=0.70;;;0", result.String())
+	// ok
+	sample.MemPercent = 50
+	result = checkMachine(machine, sample, true, now, limits)
+	assertEqual(t, checkOk, result.Code)
+	assertEqual(t, "MACHINE OK - db: cpu 13%, mem 50%, disk 76% | cpu=13%;;90;0;100 mem=50%;;90;0;100 disk=76%;;;0;100 load1=0.50;;;0 load5=0.60;;;0 load15=0.70;;;0", result.String())
+	// too old
+	result = checkMachine(machine, sample, true, now+20*60_000, limits)
+	assertEqual(t, checkUnknown, result.Code)
+	assertEqual(t, "MACHINE UNKNOWN - db: last sample is 21m old", result.String())
+	// not found
+	result = checkMachine(machine, monibot.MachineSample{}, false, now, limits)
+	assertEqual(t, checkUnknown, result.Code)
+	assertEqual(t, "MACHINE UNKNOWN - db: no sample sent from this host", result.String())
+}
+
+func TestCheckMetric(t *testing.T) {
+	metric := monibot.Metric{Id: "x1", Name: "Queue", Type: 1}
+	value := MetricValue{Tstamp: 1000, Value: 42}
+	result := checkMetric(metric, value, true, 50, 100)
+	assertEqual(t, "METRIC OK - Queue: value 42 | value=42;50;100;0", result.String())
+	result = checkMetric(metric, value, true, 40, 100)
+	assertEqual(t, "METRIC WARNING - Queue: value 42 > 40 | value=42;40;100;0", result.String())
+	result = checkMetric(metric, value, true, 20, 40)
+	assertEqual(t, "METRIC CRITICAL - Queue: value 42 > 40 | value=42;20;40;0", result.String())
+	result = checkMetric(metric, value, true, -1, -1)
+	assertEqual(t, "METRIC OK - Queue: value 42 | value=42;;;0", result.String())
+	result = checkMetric(metric, value, false, -1, -1)
+	assertEqual(t, checkUnknown, result.Code)
+	assertEqual(t, "METRIC UNKNOWN - Queue: no value sent from this host", result.String())
+}
+
+func TestCheckNoLocal(t *testing.T) {
+	result := checkNoLocal("MACHINE", "db", "samples")
+	assertEqual(t, checkUnknown, result.Code)
+	assertEqual(t, "MACHINE UNKNOWN - db: the Monibot API does not provide samples, use -local to check the samples sent from this host", result.String())
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cvilsmeier/monibot-go"
+)
+
+// namePrefix marks an id argument as a name, e.g. "name:Backup Job".
+const namePrefix = "name:"
+
+// nameCacheTtl is the time a cached name lookup stays valid.
+const nameCacheTtl = 1 * time.Hour
+
+// nameNegativeCacheTtl is the time a cached lookup of a missing or
+// ambiguous name stays valid, so that a typo in a cron job does not
+// fetch names from the API on every run.
+const nameNegativeCacheTtl = 5 * time.Minute
+
+// name kinds
+const (
+	watchdogKind = "watchdog"
+	machineKind  = "machine"
+	metricKind   = "metric"
+)
+
+type resolverApi interface {
+	GetWatchdogs() ([]monibot.Watchdog, error)
+	GetMachines() ([]monibot.Machine, error)
+	GetMetrics() ([]monibot.Metric, error)
+}
+
+// NameError is returned by NameResolver if a name
+// cannot be resolved to exactly one id.
+type NameError struct {
+	Kind string
+	Name string
+	Ids  []string
+}
+
+func (e *NameError) Error() string {
+	if len(e.Ids) == 0 {
+		return fmt.Sprintf("%s name %q not found", e.Kind, e.Name)
+	}
+	return fmt.Sprintf("%s name %q is ambiguous, matching ids are %s", e.Kind, e.Name, strings.Join(e.Ids, ", "))
+}
+
+// NameResolver resolves 'name:<name>' arguments into ids.
+// It caches the names it fetched from the API in a local file.
+type NameResolver struct {
+	api      resolverApi
+	filename string
+	now      func() time.Time
+}
+
+func NewNameResolver(api resolverApi, filename string) *NameResolver {
+	return &NameResolver{api, filename, time.Now}
+}
+
+// nameCache is the content of the name cache file.
+type nameCache struct {
+	Kinds map[string]nameCacheEntry `json:"kinds"` // kind -> entry
+}
+
+// nameCacheEntry holds all names of one kind.
+type nameCacheEntry struct {
+	Tstamp int64               `json:"tstamp"`
+	Names  map[string][]string `json:"names"` // name -> ids
+}
+
+// Resolve returns arg unchanged if it does not start with 'name:'.
+// Otherwise it looks up the id for the name. It fetches only the
+// names of the given kind from the API, and only if they are not
+// cached or the cache entry has expired.
+func (r *NameResolver) Resolve(kind, arg string) (string, error) {
+	name, ok := strings.CutPrefix(arg, namePrefix)
+	if !ok {
+		return arg, nil
+	}
+	cache := r.loadCache()
+	entry, found := cache.Kinds[kind]
+	ids := entry.Names[name]
+	ttl := nameCacheTtl
+	if len(ids) != 1 {
+		ttl = nameNegativeCacheTtl
+	}
+	if !found || r.now().UnixMilli()-entry.Tstamp > ttl.Milliseconds() {
+		names, err := r.fetchNames(kind)
+		if err != nil {
+			return "", err
+		}
+		if cache.Kinds == nil {
+			cache.Kinds = make(map[string]nameCacheEntry)
+		}
+		cache.Kinds[kind] = nameCacheEntry{r.now().UnixMilli(), names}
+		r.saveCache(cache)
+		ids = names[name]
+	}
+	if len(ids) != 1 {
+		return "", &NameError{kind, name, ids}
+	}
+	return ids[0], nil
+}
+
+// fetchNames fetches the names of one kind from the API.
+func (r *NameResolver) fetchNames(kind string) (map[string][]string, error) {
+	names := make(map[string][]string)
+	switch kind {
+	case watchdogKind:
+		watchdogs, err := r.api.GetWatchdogs()
+		if err != nil {
+			return nil, fmt.Errorf("cannot get watchdogs: %w", err)
+		}
+		for _, watchdog := range watchdogs {
+			names[watchdog.Name] = append(names[watchdog.Name], watchdog.Id)
+		}
+	case machineKind:
+		machines, err := r.api.GetMachines()
+		if err != nil {
+			return nil, fmt.Errorf("cannot get machines: %w", err)
+		}
+		for _, machine := range machines {
+			names[machine.Name] = append(names[machine.Name], machine.Id)
+		}
+	case metricKind:
+		metrics, err := r.api.GetMetrics()
+		if err != nil {
+			return nil, fmt.Errorf("cannot get metrics: %w", err)
+		}
+		for _, metric := range metrics {
+			names[metric.Name] = append(names[metric.Name], metric.Id)
+		}
+	default:
+		return nil, fmt.Errorf("unknown kind %q", kind)
+	}
+	return names, nil
+}
+
+// loadCache loads the cache file. A missing or broken
+// cache file results in an empty cache.
+func (r *NameResolver) loadCache() nameCache {
+	var cache nameCache
+	if err := loadState(r.filename, &cache); err != nil {
+		return nameCache{}
+	}
+	return cache
+}
+
+// saveCache writes the cache file. Since the cache is
+// an optimization only, errors are ignored.
+func (r *NameResolver) saveCache(cache nameCache) {
+	_ = saveState(r.filename, cache)
+}
+
+// isNameError returns true if err is a NameError.
+func isNameError(err error) bool {
+	var nameErr *NameError
+	return errors.As(err, &nameErr)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cvilsmeier/monibot-go"
+)
+
+func TestNameResolver(t *testing.T) {
+	api := &fakeResolverApi{
+		watchdogs: []monibot.Watchdog{{Id: "w1", Name: "Backup"}},
+		machines:  []monibot.Machine{{Id: "m1", Name: "db"}, {Id: "m2", Name: "web"}, {Id: "m3", Name: "web"}},
+		metrics:   []monibot.Metric{{Id: "x1", Name: "Logins"}},
+		calls:     make(map[string]int),
+	}
+	now := time.Date(2025, 1, 4, 10, 0, 0, 0, time.UTC)
+	resolver := NewNameResolver(api, filepath.Join(t.TempDir(), "names.json"))
+	resolver.now = func() time.Time { return now }
+	// ids are returned unchanged
+	id, err := resolver.Resolve(machineKind, "0123456789")
+	assertNil(t, err)
+	assertEqual(t, "0123456789", id)
+	assertEqual(t, 0, len(api.calls))
+	// names are resolved, only the needed kind is fetched
+	id, err = resolver.Resolve(watchdogKind, "name:Backup")
+	assertNil(t, err)
+	assertEqual(t, "w1", id)
+	assertEqual(t, 1, api.calls[watchdogKind])
+	assertEqual(t, 0, api.calls[machineKind])
+	assertEqual(t, 0, api.calls[metricKind])
+	id, err = resolver.Resolve(machineKind, "name:db")
+	assertNil(t, err)
+	assertEqual(t, "m1", id)
+	id, err = resolver.Resolve(metricKind, "name:Logins")
+	assertNil(t, err)
+	assertEqual(t, "x1", id)
+	assertEqual(t, 1, api.calls[watchdogKind])
+	assertEqual(t, 1, api.calls[machineKind])
+	assertEqual(t, 1, api.calls[metricKind])
+	// cache file is used by a new resolver
+	resolver = NewNameResolver(api, resolver.filename)
+	resolver.now = func() time.Time { return now.Add(10 * time.Minute) }
+	id, err = resolver.Resolve(metricKind, "name:Logins")
+	assertNil(t, err)
+	assertEqual(t, "x1", id)
+	assertEqual(t, 1, api.calls[metricKind])
+	// expired cache is refreshed
+	resolver.now = func() time.Time { return now.Add(2 * time.Hour) }
+	id, err = resolver.Resolve(metricKind, "name:Logins")
+	assertNil(t, err)
+	assertEqual(t, "x1", id)
+	assertEqual(t, 2, api.calls[metricKind])
+	// not found
+	_, err = resolver.Resolve(machineKind, "name:Backup")
+	assertEqual(t, true, isNameError(err))
+	assertEqual(t, `machine name "Backup" not found`, err.Error())
+	assertEqual(t, 2, api.calls[machineKind])
+	// ambiguous
+	_, err = resolver.Resolve(machineKind, "name:web")
+	assertEqual(t, true, isNameError(err))
+	assertEqual(t, `machine name "web" is ambiguous, matching ids are m2, m3`, err.Error())
+	// missing and ambiguous names are cached for a short time
+	assertEqual(t, 2, api.calls[machineKind])
+	resolver.now = func() time.Time { return now.Add(2*time.Hour + 4*time.Minute) }
+	_, err = resolver.Resolve(machineKind, "name:Backup")
+	assertEqual(t, true, isNameError(err))
+	assertEqual(t, 2, api.calls[machineKind])
+	resolver.now = func() time.Time { return now.Add(2*time.Hour + 6*time.Minute) }
+	_, err = resolver.Resolve(machineKind, "name:Backup")
+	assertEqual(t, true, isNameError(err))
+	assertEqual(t, 3, api.calls[machineKind])
+	// api error
+	api.err = errors.New("offline")
+	resolver.now = func() time.Time { return now.Add(3 * time.Hour) }
+	_, err = resolver.Resolve(machineKind, "name:other")
+	assertEqual(t, false, isNameError(err))
+	assertEqual(t, "cannot get machines: offline", err.Error())
+}
+
+type fakeResolverApi struct {
+	watchdogs []monibot.Watchdog
+	machines  []monibot.Machine
+	metrics   []monibot.Metric
+	err       error
+	calls     map[string]int // kind -> number of calls
+}
+
+func (f *fakeResolverApi) GetWatchdogs() ([]monibot.Watchdog, error) {
+	f.calls[watchdogKind]++
+	return f.watchdogs, f.err
+}
+
+func (f *fakeResolverApi) GetMachines() ([]monibot.Machine, error) {
+	f.calls[machineKind]++
+	return f.machines, f.err
+}
+
+func (f *fakeResolverApi) GetMetrics() ([]monibot.Metric, error) {
+	f.calls[metricKind]++
+	return f.metrics, f.err
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stopTimeout is the time 'moni stop' waits for a process to stop.
+const stopTimeout = 10 * time.Second
+
+// errLocked is returned by lockFile if another process holds the lock.
+var errLocked = errors.New("locked")
+
+// PidFile is a file that holds the pid of a running moni process.
+// It is locked while the process runs, so that a second process
+// with the same pid file refuses to start.
+type PidFile struct {
+	filename string
+	file     *os.File
+}
+
+// CreatePidFile creates and locks a pid file, and writes the pid of
+// this process into it. It fails if another process holds the lock.
+func CreatePidFile(filename string) (*PidFile, error) {
+	f, err := lockFile(filename, true)
+	if err != nil {
+		if errors.Is(err, errLocked) {
+			pid, _, _ := readPidFile(filename)
+			return nil, fmt.Errorf("already running with pid %d, see %s", pid, filename)
+		}
+		return nil, err
+	}
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &PidFile{filename, f}, nil
+}
+
+// Remove removes and unlocks the pid file.
+func (p *PidFile) Remove() error {
+	// remove while still locked, so that we do not remove the pid file
+	// of a process that has started in between
+	err := os.Remove(p.filename)
+	p.file.Close()
+	return err
+}
+
+// readPidFile reads the pid from a pid file, and returns whether the
+// process is running, i.e. holds the lock on the pid file. A missing
+// pid file means not running.
+func readPidFile(filename string) (int, bool, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid pid file %s: %q", filename, data)
+	}
+	f, err := lockFile(filename, false)
+	if err != nil {
+		if errors.Is(err, errLocked) {
+			return pid, true, nil
+		}
+		if os.IsNotExist(err) {
+			return pid, false, nil
+		}
+		return pid, false, err
+	}
+	f.Close()
+	return pid, false, nil
+}
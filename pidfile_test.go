@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPidFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "moni.pid")
+	// not running
+	_, running, err := readPidFile(filename)
+	assertNil(t, err)
+	assertEqual(t, false, running)
+	// running
+	p, err := CreatePidFile(filename)
+	assertNil(t, err)
+	pid, running, err := readPidFile(filename)
+	assertNil(t, err)
+	assertEqual(t, os.Getpid(), pid)
+	assertEqual(t, true, running)
+	// second instance refuses to start
+	_, err = CreatePidFile(filename)
+	assertEqual(t, fmt.Sprintf("already running with pid %d, see %s", os.Getpid(), filename), err.Error())
+	// removed
+	assertNil(t, p.Remove())
+	_, running, err = readPidFile(filename)
+	assertNil(t, err)
+	assertEqual(t, false, running)
+	// stale pid file, e.g. after a crash
+	assertNil(t, os.WriteFile(filename, []byte("12345678\n"), 0644))
+	pid, running, err = readPidFile(filename)
+	assertNil(t, err)
+	assertEqual(t, 12345678, pid)
+	assertEqual(t, false, running)
+	p, err = CreatePidFile(filename)
+	assertNil(t, err)
+	pid, _, err = readPidFile(filename)
+	assertNil(t, err)
+	assertEqual(t, os.Getpid(), pid)
+	assertNil(t, p.Remove())
+	// invalid pid file
+	assertNil(t, os.WriteFile(filename, []byte("x"), 0644))
+	_, _, err = readPidFile(filename)
+	assertEqual(t, fmt.Sprintf("invalid pid file %s: \"x\"", filename), err.Error())
+}
+
+func TestPidFileReadOnly(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "moni.pid")
+	p, err := CreatePidFile(filename)
+	assertNil(t, err)
+	defer p.Remove()
+	// like a root-owned pid file, read by another user (root can
+	// open it for writing anyway)
+	assertNil(t, os.Chmod(filename, 0444))
+	pid, running, err := readPidFile(filename)
+	assertNil(t, err)
+	assertEqual(t, os.Getpid(), pid)
+	assertEqual(t, true, running)
+}
@@ -0,0 +1,67 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFile opens filename and locks it exclusively. It returns
+// errLocked if another process holds the lock. If create is false, it
+// opens the file read-only and takes a shared lock, which is enough to
+// see whether another process holds the lock, and works for users that
+// may not write the file.
+func lockFile(filename string, create bool) (*os.File, error) {
+	flags, how := os.O_RDONLY, syscall.LOCK_SH
+	if create {
+		flags, how = os.O_RDWR|os.O_CREATE, syscall.LOCK_EX
+	}
+	for {
+		f, err := os.OpenFile(filename, flags, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB); err != nil {
+			f.Close()
+			if errors.Is(err, syscall.EWOULDBLOCK) {
+				return nil, errLocked
+			}
+			return nil, err
+		}
+		// another process may have removed the file, and created a new
+		// one, between our open and flock, then we locked a file that
+		// is gone and must try again
+		same, err := isFile(f, filename)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if same {
+			return f, nil
+		}
+		f.Close()
+	}
+}
+
+// isFile reports whether f is the file that filename names.
+func isFile(f *os.File, filename string) (bool, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	pathInfo, err := os.Stat(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return os.SameFile(info, pathInfo), nil
+}
+
+// terminateProcess asks a process to terminate.
+func terminateProcess(p *os.Process) error {
+	return p.Signal(syscall.SIGTERM)
+}
@@ -0,0 +1,43 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFile opens filename and locks it exclusively, by denying write
+// access to other processes. It returns errLocked if another process
+// holds the lock. If create is false, it opens the file read-only,
+// which still fails while another process holds the lock.
+func lockFile(filename string, create bool) (*os.File, error) {
+	name, err := syscall.UTF16PtrFromString(filename)
+	if err != nil {
+		return nil, err
+	}
+	var access, disposition uint32 = syscall.GENERIC_READ, syscall.OPEN_EXISTING
+	if create {
+		access, disposition = syscall.GENERIC_READ|syscall.GENERIC_WRITE, syscall.OPEN_ALWAYS
+	}
+	h, err := syscall.CreateFile(name, access,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_DELETE, nil, disposition, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		const errorSharingViolation syscall.Errno = 32
+		if errors.Is(err, errorSharingViolation) {
+			return nil, errLocked
+		}
+		if errors.Is(err, syscall.ERROR_FILE_NOT_FOUND) {
+			return nil, os.ErrNotExist
+		}
+		return nil, err
+	}
+	return os.NewFile(uintptr(h), filename), nil
+}
+
+// terminateProcess asks a process to terminate. Windows has no
+// SIGTERM, so the process is killed.
+func terminateProcess(p *os.Process) error {
+	return p.Kill()
+}
@@ -1,24 +1,27 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/shirou/gopsutil/v4/cpu"
 	"github.com/shirou/gopsutil/v4/disk"
+	"github.com/shirou/gopsutil/v4/host"
 	"github.com/shirou/gopsutil/v4/load"
 	"github.com/shirou/gopsutil/v4/mem"
 	"github.com/shirou/gopsutil/v4/net"
 )
 
 type Platform struct {
-	verbose bool
+	logger *slog.Logger
 }
 
-func NewPlatform(verbose bool) *Platform {
-	return &Platform{verbose}
+func NewPlatform(logger *slog.Logger) *Platform {
+	return &Platform{logger}
 }
 
 func (p *Platform) UnixMilli() int64 {
@@ -54,7 +57,7 @@ func (p *Platform) DiskPercent() (float64, error) {
 	for _, partition := range partitions {
 		usage, err := disk.Usage(partition.Mountpoint)
 		if err != nil {
-			log.Printf("WARNING: cannot disk.Usage(%q): %s", partition.Mountpoint, err)
+			p.logger.Warn("cannot disk.Usage()", "mountpoint", partition.Mountpoint, "error", err)
 			continue
 		}
 		p.debugf("Platform.DiskPercent(): %q (%q)  Total=%d, Used=%d", partition.Device, partition.Mountpoint, usage.Total, usage.Used)
@@ -84,7 +87,7 @@ func (p *Platform) DiskBytes() (uint64, uint64, error) {
 	var readBytes, writeBytes uint64
 	iocMap, err := disk.IOCounters(names...)
 	if err != nil {
-		log.Printf("WARNING: cannot disk.IOCounters(%v): %s", names, err)
+		p.logger.Warn(fmt.Sprintf("cannot disk.IOCounters(%v)", names), "error", err)
 	}
 	for name, ioc := range iocMap {
 		p.debugf("Platform.DiskBytes(): %q  ReadBytes=%d, WriteBytes=%d", name, ioc.ReadBytes, ioc.WriteBytes)
@@ -113,8 +116,86 @@ func (p *Platform) NetBytes() (uint64, uint64, error) {
 	return recvBytes, sendBytes, nil
 }
 
+func (p *Platform) Inventory() (Inventory, error) {
+	info, err := host.Info()
+	if err != nil {
+		return Inventory{}, fmt.Errorf("cannot host.Info(): %w", err)
+	}
+	inv := Inventory{
+		Hostname:        info.Hostname,
+		OS:              info.OS,
+		Platform:        info.Platform,
+		PlatformVersion: info.PlatformVersion,
+		KernelVersion:   info.KernelVersion,
+		KernelArch:      info.KernelArch,
+		Uptime:          time.Duration(info.Uptime) * time.Second,
+	}
+	// cpu
+	cpuInfos, err := cpu.Info()
+	if err != nil {
+		p.logger.Warn("cannot cpu.Info()", "error", err)
+	} else if len(cpuInfos) > 0 {
+		inv.CpuModel = cpuInfos[0].ModelName
+	}
+	if inv.CpuCores, err = cpu.Counts(false); err != nil {
+		p.logger.Warn("cannot cpu.Counts(false)", "error", err)
+	}
+	if inv.CpuThreads, err = cpu.Counts(true); err != nil {
+		p.logger.Warn("cannot cpu.Counts(true)", "error", err)
+	}
+	// mem
+	vm, err := mem.VirtualMemory()
+	if err != nil {
+		p.logger.Warn("cannot mem.VirtualMemory()", "error", err)
+	} else {
+		inv.MemTotal = vm.Total
+	}
+	// filesystems
+	partitions, err := disk.Partitions(false)
+	if err != nil {
+		p.logger.Warn("cannot disk.Partitions()", "error", err)
+	}
+	for _, partition := range partitions {
+		usage, err := disk.Usage(partition.Mountpoint)
+		if err != nil {
+			p.logger.Warn("cannot disk.Usage()", "mountpoint", partition.Mountpoint, "error", err)
+			continue
+		}
+		inv.Filesystems = append(inv.Filesystems, InventoryFilesystem{partition.Device, partition.Mountpoint, partition.Fstype, usage.Total, usage.Used, usage.UsedPercent})
+	}
+	// network interfaces
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		p.logger.Warn("cannot net.Interfaces()", "error", err)
+	}
+	for _, iface := range ifaces {
+		var addrs []string
+		for _, addr := range iface.Addrs {
+			addrs = append(addrs, addr.Addr)
+		}
+		inv.Interfaces = append(inv.Interfaces, InventoryInterface{iface.Name, iface.HardwareAddr, addrs})
+	}
+	// listening ports
+	conns, err := net.Connections("tcp")
+	if err != nil {
+		p.logger.Warn("cannot net.Connections()", "error", err)
+	}
+	for _, conn := range conns {
+		if conn.Status == "LISTEN" {
+			inv.ListenPorts = append(inv.ListenPorts, InventoryPort{conn.Laddr.IP, conn.Laddr.Port, conn.Pid})
+		}
+	}
+	sort.Slice(inv.ListenPorts, func(i, j int) bool {
+		if inv.ListenPorts[i].Port != inv.ListenPorts[j].Port {
+			return inv.ListenPorts[i].Port < inv.ListenPorts[j].Port
+		}
+		return inv.ListenPorts[i].Addr < inv.ListenPorts[j].Addr
+	})
+	return inv, nil
+}
+
 func (p *Platform) debugf(f string, a ...any) {
-	if p.verbose {
-		log.Printf("VERBOSE: "+f, a...)
+	if p.logger.Enabled(context.Background(), slog.LevelDebug) {
+		p.logger.Debug(fmt.Sprintf(f, a...))
 	}
 }
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// HttpStats are statistics of the HTTP requests that moni sends.
+type HttpStats struct {
+	Requests int64   `json:"requests"`
+	Errors   int64   `json:"errors"`  // transport errors and status codes >= 400
+	Retries  int64   `json:"retries"` // requests repeated after an error
+	Seconds  float64 `json:"seconds"` // total request duration
+}
+
+// statsTransport is a http.RoundTripper that counts requests into stats.
+type statsTransport struct {
+	base       http.RoundTripper
+	now        func() time.Time
+	mu         sync.Mutex
+	stats      HttpStats
+	lastFailed string // method and url of last failed request
+}
+
+func newStatsTransport(base http.RoundTripper) *statsTransport {
+	return &statsTransport{base: base, now: time.Now}
+}
+
+func (t *statsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := t.now()
+	resp, err := t.base.RoundTrip(req)
+	seconds := t.now().Sub(start).Seconds()
+	key := req.Method + " " + req.URL.String()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats.Requests++
+	t.stats.Seconds += seconds
+	if key == t.lastFailed {
+		t.stats.Retries++
+	}
+	t.lastFailed = ""
+	if err != nil || resp.StatusCode >= 400 {
+		t.stats.Errors++
+		t.lastFailed = key
+	}
+	return resp, err
+}
+
+// Stats returns the current statistics.
+func (t *statsTransport) Stats() HttpStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats
+}
+
+// writePrometheus writes a status report in Prometheus text format.
+func writePrometheus(w io.Writer, r StatusReport) {
+	metric := func(name, typ, help string, value any) {
+		fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+		switch v := value.(type) {
+		case float64:
+			fmt.Fprintf(w, "%s %s\n", name, strconv.FormatFloat(v, 'g', -1, 64))
+		default:
+			fmt.Fprintf(w, "%s %d\n", name, v)
+		}
+	}
+	healthy := 0
+	if r.Healthy {
+		healthy = 1
+	}
+	metric("moni_up", "gauge", "1 if moni is healthy, 0 if not.", healthy)
+	metric("moni_uptime_seconds", "gauge", "Seconds since moni was started.", r.UptimeSeconds)
+	metric("moni_sends_total", "counter", "Send operations to the Monibot API.", r.Successes+r.Failures)
+	metric("moni_send_failures_total", "counter", "Send operations to the Monibot API that failed.", r.Failures)
+	metric("moni_send_consecutive_failures", "gauge", "Send operations that failed since the last success.", r.ConsecutiveErrors)
+	metric("moni_http_requests_total", "counter", "HTTP requests to the Monibot API, including retries.", r.Http.Requests)
+	metric("moni_http_errors_total", "counter", "HTTP requests that failed or returned a status >= 400.", r.Http.Errors)
+	metric("moni_http_retries_total", "counter", "HTTP requests that were repeated after an error.", r.Http.Retries)
+	fmt.Fprintf(w, "# HELP moni_http_request_duration_seconds Duration of HTTP requests.\n")
+	fmt.Fprintf(w, "# TYPE moni_http_request_duration_seconds summary\n")
+	fmt.Fprintf(w, "moni_http_request_duration_seconds_sum %s\n", strconv.FormatFloat(r.Http.Seconds, 'g', -1, 64))
+	fmt.Fprintf(w, "moni_http_request_duration_seconds_count %d\n", r.Http.Requests)
+	if s := r.LastSample; s != nil {
+		metric("moni_machine_sample_timestamp_seconds", "gauge", "Time of the last machine sample.", float64(s.Tstamp)/1000)
+		metric("moni_machine_load1", "gauge", "Load average over 1 minute.", s.Load1)
+		metric("moni_machine_load5", "gauge", "Load average over 5 minutes.", s.Load5)
+		metric("moni_machine_load15", "gauge", "Load average over 15 minutes.", s.Load15)
+		metric("moni_machine_cpu_percent", "gauge", "CPU usage in percent.", s.CpuPercent)
+		metric("moni_machine_mem_percent", "gauge", "Memory usage in percent.", s.MemPercent)
+		metric("moni_machine_disk_percent", "gauge", "Disk usage in percent.", s.DiskPercent)
+		metric("moni_machine_disk_read_bytes", "gauge", "Bytes read from disk in the last sample interval.", s.DiskRead)
+		metric("moni_machine_disk_write_bytes", "gauge", "Bytes written to disk in the last sample interval.", s.DiskWrite)
+		metric("moni_machine_net_recv_bytes", "gauge", "Bytes received from network in the last sample interval.", s.NetRecv)
+		metric("moni_machine_net_send_bytes", "gauge", "Bytes sent to network in the last sample interval.", s.NetSend)
+	}
+}
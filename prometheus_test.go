@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cvilsmeier/monibot-go"
+)
+
+func TestStatsTransport(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= 2 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+	transport := newStatsTransport(http.DefaultTransport)
+	client := &http.Client{Transport: transport}
+	for range 3 {
+		resp, err := client.Post(server.URL+"/api/heartbeat", "text/plain", nil)
+		assertNil(t, err)
+		resp.Body.Close()
+	}
+	resp, err := client.Get(server.URL + "/api/ping")
+	assertNil(t, err)
+	resp.Body.Close()
+	stats := transport.Stats()
+	assertEqual(t, int64(4), stats.Requests)
+	assertEqual(t, int64(2), stats.Errors)
+	assertEqual(t, int64(2), stats.Retries)
+	assertEqual(t, true, stats.Seconds > 0)
+}
+
+func TestWritePrometheus(t *testing.T) {
+	var sb strings.Builder
+	writePrometheus(&sb, StatusReport{
+		Healthy:       true,
+		UptimeSeconds: 60,
+		Successes:     3,
+		Failures:      1,
+		Http:          HttpStats{Requests: 5, Errors: 2, Retries: 1, Seconds: 0.25},
+	})
+	text := sb.String()
+	assertEqual(t, true, strings.Contains(text, "# TYPE moni_up gauge\nmoni_up 1\n"))
+	assertEqual(t, true, strings.Contains(text, "\nmoni_sends_total 4\n"))
+	assertEqual(t, true, strings.Contains(text, "\nmoni_send_failures_total 1\n"))
+	assertEqual(t, true, strings.Contains(text, "\nmoni_http_retries_total 1\n"))
+	assertEqual(t, true, strings.Contains(text, "\nmoni_http_request_duration_seconds_sum 0.25\nmoni_http_request_duration_seconds_count 5\n"))
+	assertEqual(t, false, strings.Contains(text, "moni_machine_"))
+	// with sample
+	sb.Reset()
+	writePrometheus(&sb, StatusReport{LastSample: &monibot.MachineSample{
+		Tstamp:     1714564800500,
+		Load1:      1.5,
+		CpuPercent: 12,
+		NetSend:    1024,
+	}})
+	text = sb.String()
+	assertEqual(t, true, strings.Contains(text, "# HELP moni_machine_cpu_percent CPU usage in percent.\n# TYPE moni_machine_cpu_percent gauge\nmoni_machine_cpu_percent 12\n"))
+	assertEqual(t, true, strings.Contains(text, "\nmoni_machine_load1 1.5\n"))
+	assertEqual(t, true, strings.Contains(text, "\nmoni_machine_net_send_bytes 1024\n"))
+	assertEqual(t, true, strings.Contains(text, "\nmoni_machine_sample_timestamp_seconds 1.7145648005e+09\n"))
+	assertEqual(t, true, strings.Contains(text, "\nmoni_up 0\n"))
+	// every metric has HELP and TYPE
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			name, _, _ := strings.Cut(line, " ")
+			name = strings.TrimSuffix(strings.TrimSuffix(name, "_sum"), "_count")
+			assertEqual(t, true, strings.Contains(text, fmt.Sprintf("# TYPE %s ", name)))
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"sync"
+)
+
+// secrets holds the secrets, like the API key, that moni masks in all
+// its output: stdout, log messages and verbose API logs.
+var secrets = &Redactor{}
+
+// Redactor masks secrets in strings. It is safe for concurrent use.
+type Redactor struct {
+	mu       sync.Mutex
+	disabled bool
+	secrets  []string
+}
+
+// Add adds a secret. Empty secrets are ignored.
+func (r *Redactor) Add(secret string) {
+	if secret == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.secrets = append(r.secrets, secret)
+}
+
+// Disable turns off masking, e.g. for -showSecrets.
+func (r *Redactor) Disable() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.disabled = true
+}
+
+// Redact replaces all secrets in s with their masked form.
+func (r *Redactor) Redact(s string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.disabled {
+		return s
+	}
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, maskSecret(secret))
+	}
+	return s
+}
+
+// Writer wraps w into an io.Writer that masks secrets. Each Write is
+// masked on its own, so a secret that is split across two writes is
+// not masked. The log handlers and fprtf write whole lines.
+func (r *Redactor) Writer(w io.Writer) io.Writer {
+	return &redactWriter{r, w}
+}
+
+type redactWriter struct {
+	r *Redactor
+	w io.Writer
+}
+
+func (w *redactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, w.r.Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// minReportInterval is the minimum interval for sending reports.
+const minReportInterval = 5 * time.Minute
+
+// ReportSection is a section in a machine text report.
+// It contains either the output of a shell command or
+// the content of a file.
+type ReportSection struct {
+	Title   string
+	Command string
+	File    string
+	Timeout time.Duration
+	MaxSize int // 0 means no limit
+}
+
+// parseReport parses a report definition. Each section of the
+// definition is a report section, keys are 'command', 'file',
+// 'timeout' and 'maxSize'. Example:
+//
+//	[Disk usage]
+//	command = df -h
+//	timeout = 10s
+//	maxSize = 10K
+//
+//	[Hosts]
+//	file = /etc/hosts
+func parseReport(r io.Reader) ([]ReportSection, error) {
+	defs, err := parseSections(r)
+	if err != nil {
+		return nil, err
+	}
+	var sections []ReportSection
+	for _, def := range defs {
+		section := ReportSection{Title: def.Title, Timeout: defaultExecTimeout}
+		for _, kv := range def.Keys {
+			switch kv.Key {
+			case "command":
+				section.Command = kv.Value
+			case "file":
+				section.File = kv.Value
+			case "timeout":
+				timeout, err := time.ParseDuration(kv.Value)
+				if err != nil || timeout <= 0 {
+					return nil, fmt.Errorf("line %d: invalid timeout %q", kv.Line, kv.Value)
+				}
+				section.Timeout = timeout
+			case "maxSize":
+				maxSize, err := parseSize(kv.Value)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", kv.Line, err)
+				}
+				section.MaxSize = maxSize
+			default:
+				return nil, fmt.Errorf("line %d: unknown key %q", kv.Line, kv.Key)
+			}
+		}
+		if (section.Command == "") == (section.File == "") {
+			return nil, fmt.Errorf("section [%s]: need either command or file", section.Title)
+		}
+		sections = append(sections, section)
+	}
+	return sections, nil
+}
+
+// parseSize parses a size in bytes, with optional 'K' or 'M' suffix.
+func parseSize(s string) (int, error) {
+	factor := 1
+	digits := s
+	switch {
+	case strings.HasSuffix(s, "K"):
+		factor = 1024
+		digits = strings.TrimSuffix(s, "K")
+	case strings.HasSuffix(s, "M"):
+		factor = 1024 * 1024
+		digits = strings.TrimSuffix(s, "M")
+	}
+	n, err := strconv.Atoi(digits)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * factor, nil
+}
+
+// shellArgs returns the args for running a command in the system shell.
+func shellArgs(command string) []string {
+	if runtime.GOOS == "windows" {
+		return []string{"cmd", "/C", command}
+	}
+	return []string{"sh", "-c", command}
+}
+
+// sectionText runs the command or reads the file of a section
+// and formats it as text.
+func sectionText(section ReportSection) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "== %s ==\n", section.Title)
+	var body string
+	if section.Command != "" {
+		fmt.Fprintf(&sb, "$ %s\n", section.Command)
+		result, err := execCommand(context.Background(), shellArgs(section.Command), section.Timeout)
+		switch {
+		case err != nil:
+			body = fmt.Sprintf("cannot run command: %s\n", err)
+		case result.TimedOut:
+			body = string(result.Output) + fmt.Sprintf("(timeout after %s)\n", fmtDuration(section.Timeout))
+		case result.ExitCode != 0:
+			body = string(result.Output) + fmt.Sprintf("(exit code %d)\n", result.ExitCode)
+		default:
+			body = string(result.Output)
+		}
+	} else {
+		data, err := os.ReadFile(section.File)
+		if err != nil {
+			body = fmt.Sprintf("cannot read file: %s\n", err)
+		} else {
+			body = string(data)
+		}
+	}
+	if section.MaxSize > 0 {
+		body = truncateText(body, section.MaxSize, truncateTail)
+	}
+	sb.WriteString(withNewline(body))
+	return sb.String()
+}
+
+// buildReport assembles the sections into one text of at most maxSize bytes.
+func buildReport(sections []ReportSection, maxSize int) string {
+	texts := make([]string, 0, len(sections))
+	for _, section := range sections {
+		texts = append(texts, sectionText(section))
+	}
+	return truncateText(strings.Join(texts, "\n"), maxSize, truncateTail)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseReport(t *testing.T) {
+	sections, err := parseReport(strings.NewReader(`
+# a report
+[Disk usage]
+command = df -h
+timeout = 10s
+maxSize = 10K
+
+[Hosts]
+file = /etc/hosts
+`))
+	assertNil(t, err)
+	assertEqual(t, 2, len(sections))
+	assertEqual(t, ReportSection{"Disk usage", "df -h", "", 10 * time.Second, 10 * 1024}, sections[0])
+	assertEqual(t, ReportSection{"Hosts", "", "/etc/hosts", defaultExecTimeout, 0}, sections[1])
+	// errors
+	parseErr := func(s string) string {
+		_, err := parseReport(strings.NewReader(s))
+		if err == nil {
+			return ""
+		}
+		return err.Error()
+	}
+	assertEqual(t, "no sections", parseErr("# empty"))
+	assertEqual(t, "line 1: expected [title]", parseErr("command = ls"))
+	assertEqual(t, "line 2: expected 'key = value'", parseErr("[A]\ncommand"))
+	assertEqual(t, `line 2: unknown key "cmd"`, parseErr("[A]\ncmd = ls"))
+	assertEqual(t, `line 2: invalid timeout "soon"`, parseErr("[A]\ntimeout = soon"))
+	assertEqual(t, `line 2: invalid size "10G"`, parseErr("[A]\nmaxSize = 10G"))
+	assertEqual(t, "section [A]: need either command or file", parseErr("[A]\ntimeout = 1s"))
+	assertEqual(t, "section [A]: need either command or file", parseErr("[A]\ncommand = ls\nfile = /etc/hosts"))
+}
+
+func TestBuildReport(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	filename := filepath.Join(t.TempDir(), "motd")
+	assertNil(t, os.WriteFile(filename, []byte("hello"), 0600))
+	sections := []ReportSection{
+		{Title: "Echo", Command: "echo one; echo two", Timeout: time.Second},
+		{Title: "Fail", Command: "exit 2", Timeout: time.Second},
+		{Title: "Motd", File: filename},
+		{Title: "Missing", File: filename + ".missing"},
+	}
+	text := buildReport(sections, 1000)
+	text = strings.ReplaceAll(text, filename, "FILE")
+	assertEqual(t, "== Echo ==\n$ echo one; echo two\none\ntwo\n\n"+
+		"== Fail ==\n$ exit 2\n(exit code 2)\n\n"+
+		"== Motd ==\nhello\n\n"+
+		"== Missing ==\ncannot read file: open FILE.missing: no such file or directory\n", text)
+	// report is truncated
+	text = buildReport(sections[:1], 36)
+	assertEqual(t, "== Echo ==\n... 30 bytes omitted ...\n", text)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// retry defaults
+const (
+	defaultTimeout  = 30 * time.Second
+	defaultMaxDelay = 1 * time.Minute
+)
+
+// RetryPolicy controls how often and how long moni retries a request.
+type RetryPolicy struct {
+	Trials   int           // max. number of attempts, >= 1
+	Delay    time.Duration // delay after the first failed attempt
+	MaxDelay time.Duration // max. delay between attempts
+	Timeout  time.Duration // timeout of one attempt, 0 means none
+	Deadline time.Duration // total time of all attempts, 0 means none
+}
+
+// backoff returns the delay after a failed attempt (1-based): Delay
+// doubled for each further attempt, capped at MaxDelay, with a random
+// jitter that lowers it by up to 20 percent.
+func (p RetryPolicy) backoff(attempt int, random float64) time.Duration {
+	d := p.Delay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, p.MaxDelay)
+	return d - time.Duration(float64(d)*0.2*random)
+}
+
+// MaxDuration returns the max. total time of all attempts.
+func (p RetryPolicy) MaxDuration() time.Duration {
+	var d time.Duration
+	for attempt := 1; attempt <= p.Trials; attempt++ {
+		d += p.Timeout
+		if attempt < p.Trials {
+			d += p.backoff(attempt, 0)
+		}
+	}
+	if p.Deadline > 0 {
+		d = min(d, p.Deadline)
+	}
+	return d
+}
+
+// retryable reports whether a failed attempt should be retried. Transport
+// errors, timeouts, 5xx, 408 and 429 are retryable. Other 4xx, like 401
+// for an invalid apiKey, are not, because retrying would not help.
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	switch {
+	case resp.StatusCode >= 500:
+		return true
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests:
+		return true
+	}
+	return false
+}
+
+// retryTransport is a http.RoundTripper that retries failed requests
+// according to a RetryPolicy.
+type retryTransport struct {
+	base   http.RoundTripper
+	policy RetryPolicy
+	now    func() time.Time
+	sleep  func(ctx context.Context, d time.Duration) error
+	random func() float64
+}
+
+func newRetryTransport(base http.RoundTripper, policy RetryPolicy) *retryTransport {
+	return &retryTransport{base, policy, time.Now, sleepContext, rand.Float64}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var deadline time.Time
+	if t.policy.Deadline > 0 {
+		deadline = t.now().Add(t.policy.Deadline)
+	}
+	for attempt := 1; ; attempt++ {
+		resp, err := t.roundTrip(req, attempt, deadline)
+		if err == nil && resp.StatusCode < 400 {
+			return resp, nil
+		}
+		if !retryable(resp, err) || attempt >= t.policy.Trials || (req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+		delay := t.policy.backoff(attempt, t.random())
+		if !deadline.IsZero() && !t.now().Add(delay).Before(deadline) {
+			return resp, err
+		}
+		var reason string
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		slog.Warn("request failed, will retry", "method", req.Method, "path", req.URL.Path, "attempt", attempt, "delay", fmtDuration(delay.Round(time.Millisecond)), "error", reason)
+		if err := t.sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// roundTrip sends one attempt, with a timeout that ends at the
+// earlier of policy.Timeout and deadline.
+func (t *retryTransport) roundTrip(req *http.Request, attempt int, deadline time.Time) (*http.Response, error) {
+	timeout := t.policy.Timeout
+	if !deadline.IsZero() {
+		remaining := deadline.Sub(t.now())
+		if timeout == 0 || remaining < timeout {
+			timeout = remaining
+		}
+	}
+	ctx, cancel := req.Context(), context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	r := req.WithContext(ctx)
+	if attempt > 1 && req.Body != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		r = req.Clone(ctx)
+		r.Body = body
+	}
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		cancel()
+		if ctx.Err() == context.DeadlineExceeded && req.Context().Err() == nil {
+			return nil, fmt.Errorf("request timeout after %s: %w", fmtDuration(timeout.Round(time.Millisecond)), err)
+		}
+		return nil, err
+	}
+	// the body must be readable until it is closed
+	resp.Body = &cancelBody{resp.Body, cancel}
+	return resp, nil
+}
+
+// cancelBody cancels a request context when the body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// sleepContext sleeps for d, or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	p := RetryPolicy{Trials: 6, Delay: 5 * time.Second, MaxDelay: time.Minute, Timeout: 10 * time.Second}
+	var delays []string
+	for attempt := 1; attempt < p.Trials; attempt++ {
+		delays = append(delays, fmtDuration(p.backoff(attempt, 0)))
+	}
+	assertEqual(t, "5s,10s,20s,40s,1m", strings.Join(delays, ","))
+	assertEqual(t, "4s", fmtDuration(p.backoff(1, 1)))
+	assertEqual(t, "48s", fmtDuration(p.backoff(10, 1)))
+	// 6 timeouts and 5 delays
+	assertEqual(t, "3m15s", fmtDuration(p.MaxDuration()))
+	p.Deadline = 2 * time.Minute
+	assertEqual(t, "2m", fmtDuration(p.MaxDuration()))
+}
+
+func TestRetryable(t *testing.T) {
+	status := func(code int) *http.Response { return &http.Response{StatusCode: code} }
+	assertEqual(t, true, retryable(nil, errors.New("connection refused")))
+	assertEqual(t, true, retryable(nil, context.DeadlineExceeded))
+	assertEqual(t, false, retryable(nil, context.Canceled))
+	assertEqual(t, true, retryable(status(500), nil))
+	assertEqual(t, true, retryable(status(503), nil))
+	assertEqual(t, true, retryable(status(408), nil))
+	assertEqual(t, true, retryable(status(429), nil))
+	assertEqual(t, false, retryable(status(400), nil))
+	assertEqual(t, false, retryable(status(401), nil))
+	assertEqual(t, false, retryable(status(404), nil))
+}
+
+func TestRetryTransport(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	var codes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+string(body))
+		code := http.StatusOK
+		if len(codes) > 0 {
+			code, codes = codes[0], codes[1:]
+		}
+		mu.Unlock()
+		if code == 0 {
+			// slow response
+			time.Sleep(200 * time.Millisecond)
+			code = http.StatusOK
+		}
+		w.WriteHeader(code)
+		io.WriteString(w, http.StatusText(code))
+	}))
+	defer server.Close()
+	var sleeps []string
+	newTransport := func(p RetryPolicy) *retryTransport {
+		tr := newRetryTransport(http.DefaultTransport, p)
+		tr.random = func() float64 { return 0 }
+		tr.sleep = func(ctx context.Context, d time.Duration) error {
+			sleeps = append(sleeps, fmtDuration(d))
+			return nil
+		}
+		return tr
+	}
+	post := func(tr *retryTransport, codesToReturn ...int) (string, error) {
+		mu.Lock()
+		requests, codes, sleeps = nil, codesToReturn, nil
+		mu.Unlock()
+		client := &http.Client{Transport: tr}
+		resp, err := client.Post(server.URL+"/api/heartbeats/42", "text/plain", strings.NewReader("beat"))
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		return resp.Status + " " + string(data), err
+	}
+	p := RetryPolicy{Trials: 4, Delay: time.Second, MaxDelay: 3 * time.Second, Timeout: 10 * time.Second}
+	// retry 5xx and 429 with backoff, body is sent again
+	result, err := post(newTransport(p), 503, 500, 429)
+	assertNil(t, err)
+	assertEqual(t, "200 OK OK", result)
+	assertEqual(t, 4, len(requests))
+	assertEqual(t, "POST /api/heartbeats/42 beat", requests[3])
+	assertEqual(t, "1s,2s,3s", strings.Join(sleeps, ","))
+	// give up after trials
+	result, err = post(newTransport(p), 503, 503, 503, 503, 503)
+	assertNil(t, err)
+	assertEqual(t, "503 Service Unavailable Service Unavailable", result)
+	assertEqual(t, 4, len(requests))
+	// do not retry 4xx, like an invalid apiKey
+	result, err = post(newTransport(p), 401)
+	assertNil(t, err)
+	assertEqual(t, "401 Unauthorized Unauthorized", result)
+	assertEqual(t, 1, len(requests))
+	assertEqual(t, 0, len(sleeps))
+	// per-request timeout
+	p.Timeout = 50 * time.Millisecond
+	result, err = post(newTransport(p), 0, 0)
+	assertNil(t, err)
+	assertEqual(t, "200 OK OK", result)
+	assertEqual(t, 3, len(requests))
+	p.Trials = 1
+	_, err = post(newTransport(p), 0)
+	assertEqual(t, true, err != nil && strings.Contains(err.Error(), "request timeout after 50ms"))
+	// deadline: the third trial would start after the deadline
+	p = RetryPolicy{Trials: 10, Delay: time.Second, MaxDelay: time.Minute, Timeout: 10 * time.Second, Deadline: 3 * time.Second}
+	tr := newTransport(p)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tr.now = func() time.Time { return now }
+	tr.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, fmtDuration(d))
+		now = now.Add(d)
+		return nil
+	}
+	result, err = post(tr, 503, 503, 503)
+	assertNil(t, err)
+	assertEqual(t, "503 Service Unavailable Service Unavailable", result)
+	assertEqual(t, 2, len(requests))
+	assertEqual(t, "1s", strings.Join(sleeps, ","))
+}
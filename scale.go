@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// valueUnits maps unit suffixes to factors. Durations are converted
+// to milliseconds, sizes to bytes and percentages to percent.
+var valueUnits = map[string]float64{
+	"ns":  1e-6,
+	"us":  1e-3,
+	"µs":  1e-3,
+	"ms":  1,
+	"s":   1e3,
+	"m":   60e3,
+	"h":   3600e3,
+	"B":   1,
+	"KB":  1e3,
+	"MB":  1e6,
+	"GB":  1e9,
+	"TB":  1e12,
+	"KiB": 1 << 10,
+	"MiB": 1 << 20,
+	"GiB": 1 << 30,
+	"TiB": 1 << 40,
+	"%":   1,
+}
+
+// parseMetricValue parses a metric value, like "13", "0.73", "12.5ms",
+// "3GiB" or "45%". Units are converted (see valueUnits), then the value
+// is multiplied by scale and rounded to the nearest integer. Negative
+// values are not allowed.
+func parseMetricValue(s string, scale float64) (int64, error) {
+	digits := strings.TrimSpace(s)
+	factor := 1.0
+	if i := strings.LastIndexAny(digits, "0123456789.") + 1; i < len(digits) {
+		unit, ok := valueUnits[digits[i:]]
+		if !ok || i == 0 {
+			return 0, fmt.Errorf("invalid value %q", s)
+		}
+		digits, factor = digits[:i], unit
+	}
+	if strings.HasPrefix(digits, "-") {
+		return 0, fmt.Errorf("negative value %q not allowed", s)
+	}
+	if strings.HasPrefix(digits, "+") {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if factor == 1 && scale == 1 {
+		// integers are exact, even if they do not fit into a float64
+		if v, err := strconv.ParseInt(digits, 10, 64); err == nil {
+			return v, nil
+		}
+	}
+	f, err := strconv.ParseFloat(digits, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	f = math.Round(f * factor * scale)
+	if f >= math.MaxInt64 {
+		return 0, fmt.Errorf("value %q out of range", s)
+	}
+	return int64(f), nil
+}
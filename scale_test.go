@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseMetricValue(t *testing.T) {
+	parse := func(s string, scale float64) string {
+		v, err := parseMetricValue(s, scale)
+		if err != nil {
+			return err.Error()
+		}
+		return fmt.Sprint(v)
+	}
+	assertEqual(t, "13", parse("13", 1))
+	assertEqual(t, "9223372036854775807", parse("9223372036854775807", 1))
+	assertEqual(t, "1", parse("0.73", 1))
+	assertEqual(t, "730", parse("0.73", 1000))
+	assertEqual(t, "13", parse("12.5ms", 1))
+	assertEqual(t, "12500", parse("12.5ms", 1000))
+	assertEqual(t, "90000", parse("1.5m", 1))
+	assertEqual(t, "2", parse("1500us", 1))
+	assertEqual(t, "3221225472", parse("3GiB", 1))
+	assertEqual(t, "3000000000", parse("3GB", 1))
+	assertEqual(t, "45", parse("45%", 1))
+	assertEqual(t, "4500", parse("45%", 100))
+	assertEqual(t, "1000", parse("1e3", 1))
+	// errors
+	assertEqual(t, `negative value "-1" not allowed`, parse("-1", 1))
+	assertEqual(t, `negative value "-0.5ms" not allowed`, parse("-0.5ms", 1))
+	assertEqual(t, `invalid value "x"`, parse("x", 1))
+	assertEqual(t, `invalid value "ms"`, parse("ms", 1))
+	assertEqual(t, `invalid value "12 parsecs"`, parse("12 parsecs", 1))
+	assertEqual(t, `invalid value "+3"`, parse("+3", 1))
+	assertEqual(t, `invalid value "+3"`, parse("+3", 2))
+	assertEqual(t, `invalid value "NaN"`, parse("NaN", 1))
+	assertEqual(t, `value "10000000TiB" out of range`, parse("10000000TiB", 1))
+}
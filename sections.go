@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Section is a section in a definition file, like a report
+// file or a rules file. A section starts with a '[title]' line,
+// followed by 'key = value' lines. Empty lines and lines
+// starting with '#' are ignored.
+type Section struct {
+	Title string
+	Line  int
+	Keys  []SectionKey
+}
+
+// SectionKey is a 'key = value' line in a section.
+type SectionKey struct {
+	Key   string
+	Value string
+	Line  int
+}
+
+// parseSections parses a definition file into sections.
+func parseSections(r io.Reader) ([]Section, error) {
+	var sections []Section
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			title := strings.TrimSpace(line[1 : len(line)-1])
+			sections = append(sections, Section{Title: title, Line: lineNo})
+			continue
+		}
+		if len(sections) == 0 {
+			return nil, fmt.Errorf("line %d: expected [title]", lineNo)
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected 'key = value'", lineNo)
+		}
+		section := &sections[len(sections)-1]
+		section.Keys = append(section.Keys, SectionKey{strings.TrimSpace(key), strings.TrimSpace(value), lineNo})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(sections) == 0 {
+		return nil, fmt.Errorf("no sections")
+	}
+	return sections, nil
+}
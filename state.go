@@ -0,0 +1,201 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cvilsmeier/monibot-go"
+)
+
+// loadState reads a JSON file from the state directory into v.
+func loadState(filename string, v any) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// saveState writes v as JSON file into the state directory.
+// It creates the state directory if it does not exist. The file
+// is replaced atomically, so concurrent readers never see partial data.
+func saveState(filename string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return err
+	}
+	return replaceFile(filename, data)
+}
+
+// replaceFile atomically replaces filename with data. It writes a
+// temp file with a unique name in the same directory, so that
+// concurrent writers do not write the same temp file, and renames it.
+func replaceFile(filename string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// stateLockTimeout is the max. time lockState waits for a lock.
+const stateLockTimeout = 10 * time.Second
+
+// lockState locks a state file against concurrent updates by other
+// moni processes, like two cron jobs, by locking the file
+// 'filename.lock'. Hold the lock around load, modify and save. It
+// waits up to stateLockTimeout, the returned func releases the lock.
+func lockState(filename string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(stateLockTimeout)
+	for {
+		f, err := lockFile(filename+".lock", true)
+		if err == nil {
+			return func() { f.Close() }, nil
+		}
+		if !errors.Is(err, errLocked) || time.Now().After(deadline) {
+			return nil, fmt.Errorf("cannot lock %s: %w", filename, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// SampleLog records the last machine sample that was
+// sent from this host, for each machine.
+type SampleLog struct {
+	filename string
+}
+
+func NewSampleLog(filename string) *SampleLog {
+	return &SampleLog{filename}
+}
+
+// Load loads the last samples, keyed by machine id.
+// A missing or broken file results in an empty map.
+func (l *SampleLog) Load() map[string]monibot.MachineSample {
+	samples := make(map[string]monibot.MachineSample)
+	if err := loadState(l.filename, &samples); err != nil {
+		return make(map[string]monibot.MachineSample)
+	}
+	return samples
+}
+
+// Record stores the last sample for a machine.
+func (l *SampleLog) Record(machineId string, sample monibot.MachineSample) error {
+	unlock, err := lockState(l.filename)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	samples := l.Load()
+	samples[machineId] = sample
+	return saveState(l.filename, samples)
+}
+
+// MetricValue is a metric value that was sent from this host.
+type MetricValue struct {
+	Tstamp int64 `json:"tstamp"` // unix millis
+	Value  int64 `json:"value"`
+}
+
+// MetricLog records the last metric value that was
+// sent from this host, for each metric.
+type MetricLog struct {
+	filename string
+}
+
+func NewMetricLog(filename string) *MetricLog {
+	return &MetricLog{filename}
+}
+
+// Load loads the last metric values, keyed by metric id.
+// A missing or broken file results in an empty map.
+func (l *MetricLog) Load() map[string]MetricValue {
+	values := make(map[string]MetricValue)
+	if err := loadState(l.filename, &values); err != nil {
+		return make(map[string]MetricValue)
+	}
+	return values
+}
+
+// Record stores the last value for a metric.
+func (l *MetricLog) Record(metricId string, value MetricValue) error {
+	unlock, err := lockState(l.filename)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	values := l.Load()
+	values[metricId] = value
+	return saveState(l.filename, values)
+}
+
+// TextLog records the last machine text that was
+// sent from this host, for each machine.
+type TextLog struct {
+	dir string
+}
+
+func NewTextLog(dir string) *TextLog {
+	return &TextLog{dir}
+}
+
+// lastText is the content of a TextLog file.
+type lastText struct {
+	Sha256 string `json:"sha256"`
+	Text   string `json:"text"`
+}
+
+func (l *TextLog) filename(machineId string) string {
+	return filepath.Join(l.dir, machineId+".json")
+}
+
+// Load loads the last text sent for a machine.
+// It returns false if no text was recorded.
+func (l *TextLog) Load(machineId string) (string, bool) {
+	var last lastText
+	if err := loadState(l.filename(machineId), &last); err != nil {
+		return "", false
+	}
+	if last.Sha256 != textHash(last.Text) {
+		return "", false
+	}
+	return last.Text, true
+}
+
+// Record stores the last text sent for a machine.
+func (l *TextLog) Record(machineId, text string) error {
+	unlock, err := lockState(l.filename(machineId))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return saveState(l.filename(machineId), lastText{textHash(text), text})
+}
+
+// textHash returns the hex encoded SHA-256 hash of text.
+func textHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
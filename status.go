@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cvilsmeier/monibot-go"
+)
+
+// defaultStatusMaxErrors is the default number of consecutive send
+// errors after which /healthz fails.
+const defaultStatusMaxErrors = 3
+
+// Status tracks the send results of a long-running moni command,
+// like 'moni sample', so that it can be served by -statusAddr.
+// It is safe for concurrent use.
+type Status struct {
+	mu                sync.Mutex
+	command           string
+	maxErrors         int
+	now               func() time.Time
+	started           time.Time
+	lastSuccess       time.Time
+	lastFailure       time.Time
+	lastError         string
+	consecutiveErrors int
+	successes         int64
+	failures          int64
+	lastSample        *monibot.MachineSample
+	transport         *statsTransport
+}
+
+// StatusReport is the JSON representation of a Status.
+type StatusReport struct {
+	Command           string                 `json:"command"`
+	Version           string                 `json:"version"`
+	Healthy           bool                   `json:"healthy"`
+	Started           time.Time              `json:"started"`
+	UptimeSeconds     int64                  `json:"uptimeSeconds"`
+	LastSuccess       *time.Time             `json:"lastSuccess,omitempty"`
+	LastFailure       *time.Time             `json:"lastFailure,omitempty"`
+	LastError         string                 `json:"lastError,omitempty"`
+	ConsecutiveErrors int                    `json:"consecutiveErrors"`
+	Successes         int64                  `json:"successes"`
+	Failures          int64                  `json:"failures"`
+	LastSample        *monibot.MachineSample `json:"lastSample,omitempty"`
+	Http              HttpStats              `json:"http"`
+}
+
+// NewStatus creates a Status that is unhealthy after maxErrors
+// consecutive send errors.
+func NewStatus(command string, maxErrors int) *Status {
+	return newStatus(command, maxErrors, time.Now)
+}
+
+func newStatus(command string, maxErrors int, now func() time.Time) *Status {
+	return &Status{command: command, maxErrors: maxErrors, now: now, started: now()}
+}
+
+// Sent records the result of a send operation.
+func (s *Status) Sent(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.lastFailure = s.now()
+		s.lastError = err.Error()
+		s.consecutiveErrors++
+		s.failures++
+		return
+	}
+	s.lastSuccess = s.now()
+	s.consecutiveErrors = 0
+	s.successes++
+}
+
+// SetSample records the last machine sample that was sent.
+func (s *Status) SetSample(sample monibot.MachineSample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastSample = &sample
+}
+
+// Transport wraps base into a http.RoundTripper that counts the
+// requests into the status.
+func (s *Status) Transport(base http.RoundTripper) http.RoundTripper {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transport = newStatsTransport(base)
+	return s.transport
+}
+
+// Report returns the current status.
+func (s *Status) Report() StatusReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	r := StatusReport{
+		Command:           s.command,
+		Version:           Version,
+		Healthy:           s.consecutiveErrors < s.maxErrors,
+		Started:           s.started,
+		UptimeSeconds:     int64(now.Sub(s.started) / time.Second),
+		LastError:         s.lastError,
+		ConsecutiveErrors: s.consecutiveErrors,
+		Successes:         s.successes,
+		Failures:          s.failures,
+		LastSample:        s.lastSample,
+	}
+	if s.transport != nil {
+		r.Http = s.transport.Stats()
+	}
+	if !s.lastSuccess.IsZero() {
+		lastSuccess := s.lastSuccess
+		r.LastSuccess = &lastSuccess
+	}
+	if !s.lastFailure.IsZero() {
+		lastFailure := s.lastFailure
+		r.LastFailure = &lastFailure
+	}
+	return r
+}
+
+// Handler returns a http.Handler that serves the status as JSON on
+// '/' and '/status', in Prometheus text format on '/metrics', and a
+// health check on '/healthz'.
+func (s *Status) Handler() http.Handler {
+	mux := http.NewServeMux()
+	serveReport := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(s.Report())
+	}
+	mux.HandleFunc("GET /{$}", serveReport)
+	mux.HandleFunc("GET /status", serveReport)
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writePrometheus(w, s.Report())
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		report := s.Report()
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if !report.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, "unhealthy: %d consecutive errors, last error: %s\n", report.ConsecutiveErrors, report.LastError)
+			return
+		}
+		fmt.Fprintf(w, "ok\n")
+	})
+	return mux
+}
+
+// serveStatus serves status on addr in background. It returns an
+// error if it cannot listen on addr.
+func serveStatus(addr string, handler http.Handler) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(ln)
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cvilsmeier/monibot-go"
+)
+
+func TestStatus(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	status := newStatus("sample", 2, func() time.Time { return now })
+	report := status.Report()
+	assertEqual(t, "sample", report.Command)
+	assertEqual(t, true, report.Healthy)
+	assertEqual(t, true, report.LastSuccess == nil)
+	assertEqual(t, true, report.LastFailure == nil)
+	// success
+	now = now.Add(time.Minute)
+	status.Sent(nil)
+	status.SetSample(monibot.MachineSample{Tstamp: 42, CpuPercent: 12})
+	report = status.Report()
+	assertEqual(t, int64(60), report.UptimeSeconds)
+	assertEqual(t, now, *report.LastSuccess)
+	assertEqual(t, int64(1), report.Successes)
+	assertEqual(t, 12, report.LastSample.CpuPercent)
+	// failures
+	now = now.Add(time.Minute)
+	status.Sent(fmt.Errorf("timeout"))
+	report = status.Report()
+	assertEqual(t, true, report.Healthy)
+	assertEqual(t, now, *report.LastFailure)
+	assertEqual(t, "timeout", report.LastError)
+	status.Sent(fmt.Errorf("bad gateway"))
+	report = status.Report()
+	assertEqual(t, false, report.Healthy)
+	assertEqual(t, 2, report.ConsecutiveErrors)
+	assertEqual(t, int64(2), report.Failures)
+	assertEqual(t, "bad gateway", report.LastError)
+	// success resets consecutive errors
+	status.Sent(nil)
+	report = status.Report()
+	assertEqual(t, true, report.Healthy)
+	assertEqual(t, 0, report.ConsecutiveErrors)
+	assertEqual(t, int64(2), report.Failures)
+}
+
+func TestStatusHandler(t *testing.T) {
+	status := NewStatus("tail", 1)
+	server := httptest.NewServer(status.Handler())
+	defer server.Close()
+	get := func(path string) (int, string) {
+		resp, err := http.Get(server.URL + path)
+		assertNil(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		assertNil(t, err)
+		return resp.StatusCode, string(body)
+	}
+	code, body := get("/healthz")
+	assertEqual(t, 200, code)
+	assertEqual(t, "ok\n", body)
+	for _, path := range []string{"/", "/status"} {
+		code, body = get(path)
+		assertEqual(t, 200, code)
+		var report StatusReport
+		assertNil(t, json.Unmarshal([]byte(body), &report))
+		assertEqual(t, "tail", report.Command)
+		assertEqual(t, Version, report.Version)
+	}
+	status.Sent(fmt.Errorf("connection refused"))
+	code, body = get("/healthz")
+	assertEqual(t, 503, code)
+	assertEqual(t, "unhealthy: 1 consecutive errors, last error: connection refused\n", body)
+	code, body = get("/metrics")
+	assertEqual(t, 200, code)
+	assertEqual(t, true, strings.Contains(body, "\nmoni_up 0\n"))
+	code, _ = get("/nope")
+	assertEqual(t, 404, code)
+}
+
+func TestStatusReportIsCopy(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	status := newStatus("sample", 2, func() time.Time { return now })
+	status.Sent(nil)
+	status.Sent(fmt.Errorf("timeout"))
+	report := status.Report()
+	// later sends must not change a report that is being encoded
+	now = now.Add(time.Minute)
+	status.Sent(nil)
+	status.Sent(fmt.Errorf("bad gateway"))
+	assertEqual(t, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), *report.LastSuccess)
+	assertEqual(t, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), *report.LastFailure)
+	// concurrent sends and reports, run with -race
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			status.Sent(nil)
+		}
+		done <- true
+	}()
+	for i := 0; i < 100; i++ {
+		json.Marshal(status.Report())
+	}
+	<-done
+}
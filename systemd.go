@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// defaults for install-service
+const (
+	defaultUnitDir = "/etc/systemd/system"
+	defaultEnvFile = "/etc/moni/moni.env"
+)
+
+// Notifier sends service state notifications to systemd via the
+// NOTIFY_SOCKET protocol, see sd_notify(3). If moni does not run
+// as systemd service with Type=notify, it does nothing.
+type Notifier struct {
+	socket string
+}
+
+// NewNotifier creates a Notifier for the socket in $NOTIFY_SOCKET.
+func NewNotifier() *Notifier {
+	return &Notifier{os.Getenv("NOTIFY_SOCKET")}
+}
+
+// Notify sends a notification, like "READY=1" or "WATCHDOG=1".
+func (n *Notifier) Notify(state string) error {
+	if n.socket == "" {
+		return nil
+	}
+	name := n.socket
+	if strings.HasPrefix(name, "@") {
+		// abstract socket
+		name = "\x00" + name[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// ServiceSpec describes a moni command that runs as systemd service.
+type ServiceSpec struct {
+	Name     string   // unit name without '.service'
+	Exec     string   // absolute path of moni executable
+	Args     []string // moni args, including global flags
+	EnvFile  string
+	User     string
+	Watchdog time.Duration // 0 means no watchdog
+}
+
+// serviceInterval returns the send interval of a long-running moni
+// command, e.g. 'sample 42 5m'. It is used for the systemd watchdog.
+func serviceInterval(args []string) (time.Duration, error) {
+	if len(args) == 0 {
+		return 0, fmt.Errorf("empty command")
+	}
+	arg := func(i int) string {
+		if i < len(args) {
+			return args[i]
+		}
+		return ""
+	}
+	var intervalStr string
+	switch args[0] {
+	case "sample", "heartbeat":
+		intervalStr = arg(2)
+	case "report":
+		intervalStr = arg(3)
+		if strings.HasPrefix(intervalStr, "-") {
+			intervalStr = ""
+		}
+	case "tail":
+		intervalStr = fmtDuration(defaultTailInterval)
+		for i, a := range args {
+			if a == "-interval" || a == "--interval" {
+				intervalStr = arg(i + 1)
+			} else if s, ok := strings.CutPrefix(strings.TrimLeft(a, "-"), "interval="); ok && strings.HasPrefix(a, "-") {
+				intervalStr = s
+			}
+		}
+	default:
+		return 0, fmt.Errorf("command %q cannot run as service, must be sample, heartbeat, report or tail", args[0])
+	}
+	if intervalStr == "" {
+		return 0, fmt.Errorf("%s needs an interval to run as service", args[0])
+	}
+	interval, err := time.ParseDuration(intervalStr)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse interval %q: %w", intervalStr, err)
+	}
+	return interval, nil
+}
+
+// minInterval returns the min. send interval of a long-running moni
+// command. The command raises shorter intervals to it, unless -dev is set.
+func minInterval(command string) time.Duration {
+	switch command {
+	case "sample":
+		return minSampleInterval
+	case "heartbeat":
+		return minHeartbeatInterval
+	case "report":
+		return minReportInterval
+	case "tail":
+		return minTailInterval
+	}
+	return 0
+}
+
+var unitNameInvalid = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// serviceName returns a unit name for a command, like 'moni-sample-42'.
+func serviceName(args []string) string {
+	name := "moni-" + args[0]
+	if len(args) > 1 && !strings.HasPrefix(args[1], "-") {
+		name += "-" + strings.Trim(unitNameInvalid.ReplaceAllString(args[1], "-"), "-")
+	}
+	return name
+}
+
+// systemdQuote quotes an arg for a systemd ExecStart line.
+func systemdQuote(arg string) string {
+	arg = strings.ReplaceAll(arg, "%", "%%")
+	arg = strings.ReplaceAll(arg, "$", "$$")
+	if arg != "" && !strings.ContainsAny(arg, " \t\n\"'\\;") {
+		return arg
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + r.Replace(arg) + `"`
+}
+
+// unitFile returns the content of a systemd unit file.
+func unitFile(spec ServiceSpec) string {
+	execStart := []string{systemdQuote(spec.Exec)}
+	for _, arg := range spec.Args {
+		execStart = append(execStart, systemdQuote(arg))
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[Unit]\n")
+	fmt.Fprintf(&sb, "Description=moni %s\n", strings.Join(spec.Args, " "))
+	fmt.Fprintf(&sb, "Wants=network-online.target\n")
+	fmt.Fprintf(&sb, "After=network-online.target\n")
+	fmt.Fprintf(&sb, "\n")
+	fmt.Fprintf(&sb, "[Service]\n")
+	fmt.Fprintf(&sb, "Type=notify\n")
+	fmt.Fprintf(&sb, "NotifyAccess=main\n")
+	fmt.Fprintf(&sb, "EnvironmentFile=%s\n", spec.EnvFile)
+	fmt.Fprintf(&sb, "ExecStart=%s\n", strings.Join(execStart, " "))
+	if spec.User != "" {
+		fmt.Fprintf(&sb, "User=%s\n", spec.User)
+	}
+	fmt.Fprintf(&sb, "Restart=always\n")
+	fmt.Fprintf(&sb, "RestartSec=10\n")
+	if spec.Watchdog > 0 {
+		fmt.Fprintf(&sb, "WatchdogSec=%d\n", int64(spec.Watchdog/time.Second))
+	}
+	fmt.Fprintf(&sb, "\n")
+	fmt.Fprintf(&sb, "[Install]\n")
+	fmt.Fprintf(&sb, "WantedBy=multi-user.target\n")
+	return sb.String()
+}
+
+// envFile returns the content of an EnvironmentFile with the API key.
+// If apiKey is empty, the API key line is commented out.
+func envFile(apiKey string) string {
+	if apiKey == "" {
+		return fmt.Sprintf("# moni environment, see 'moni help'\n# %s=<your API key>\n", apiKeyEnvKey)
+	}
+	return fmt.Sprintf("# moni environment, see 'moni help'\n%s=%s\n", apiKeyEnvKey, apiKey)
+}
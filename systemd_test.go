@@ -0,0 +1,139 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotifier(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs unixgram sockets")
+	}
+	// no socket, no-op
+	assertNil(t, (&Notifier{}).Notify("READY=1"))
+	// local socket
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	assertNil(t, err)
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", socket)
+	notifier := NewNotifier()
+	receive := func() string {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		buf := make([]byte, 1024)
+		n, err := conn.Read(buf)
+		assertNil(t, err)
+		return string(buf[:n])
+	}
+	assertNil(t, notifier.Notify("READY=1"))
+	assertEqual(t, "READY=1", receive())
+	assertNil(t, notifier.Notify("WATCHDOG=1\nSTATUS=ok"))
+	assertEqual(t, "WATCHDOG=1\nSTATUS=ok", receive())
+	// socket gone
+	notifier = &Notifier{filepath.Join(t.TempDir(), "gone.sock")}
+	assertEqual(t, true, notifier.Notify("READY=1") != nil)
+}
+
+func TestServiceInterval(t *testing.T) {
+	interval := func(args ...string) string {
+		d, err := serviceInterval(args)
+		if err != nil {
+			return err.Error()
+		}
+		return fmtDuration(d)
+	}
+	assertEqual(t, "5m", interval("sample", "42", "5m"))
+	assertEqual(t, "10m", interval("heartbeat", "7", "10m"))
+	assertEqual(t, "heartbeat needs an interval to run as service", interval("heartbeat", "7"))
+	assertEqual(t, "1h", interval("report", "42", "report.ini", "1h", "-diff"))
+	assertEqual(t, "report needs an interval to run as service", interval("report", "42", "report.ini", "-diff"))
+	assertEqual(t, "1m", interval("tail", "app.log", "rules.ini"))
+	assertEqual(t, "30s", interval("tail", "app.log", "rules.ini", "-interval", "30s"))
+	assertEqual(t, "2m", interval("tail", "app.log", "rules.ini", "-interval=2m"))
+	assertEqual(t, `cannot parse interval "soon": time: invalid duration "soon"`, interval("sample", "42", "soon"))
+	assertEqual(t, `command "ping" cannot run as service, must be sample, heartbeat, report or tail`, interval("ping"))
+}
+
+func TestServiceName(t *testing.T) {
+	assertEqual(t, "moni-sample-42", serviceName([]string{"sample", "42", "5m"}))
+	assertEqual(t, "moni-heartbeat-name-Backup-Job", serviceName([]string{"heartbeat", "name:Backup Job", "5m"}))
+	assertEqual(t, "moni-tail-var-log-app.log", serviceName([]string{"tail", "/var/log/app.log", "rules.ini"}))
+}
+
+func TestUnitFile(t *testing.T) {
+	unit := unitFile(ServiceSpec{
+		Name:     "moni-heartbeat-7",
+		Exec:     "/usr/local/bin/moni",
+		Args:     []string{"-stateDir=/var/lib/moni", "heartbeat", "name:Backup Job", "5m"},
+		EnvFile:  "/etc/moni/moni.env",
+		User:     "moni",
+		Watchdog: 11 * time.Minute,
+	})
+	want := strings.Join([]string{
+		"[Unit]",
+		"Description=moni -stateDir=/var/lib/moni heartbeat name:Backup Job 5m",
+		"Wants=network-online.target",
+		"After=network-online.target",
+		"",
+		"[Service]",
+		"Type=notify",
+		"NotifyAccess=main",
+		"EnvironmentFile=/etc/moni/moni.env",
+		`ExecStart=/usr/local/bin/moni -stateDir=/var/lib/moni heartbeat "name:Backup Job" 5m`,
+		"User=moni",
+		"Restart=always",
+		"RestartSec=10",
+		"WatchdogSec=660",
+		"",
+		"[Install]",
+		"WantedBy=multi-user.target",
+		"",
+	}, "\n")
+	assertEqual(t, want, unit)
+}
+
+func TestSystemdQuote(t *testing.T) {
+	assertEqual(t, "5m", systemdQuote("5m"))
+	assertEqual(t, `""`, systemdQuote(""))
+	assertEqual(t, `"a b"`, systemdQuote("a b"))
+	assertEqual(t, `"say \"hi\""`, systemdQuote(`say "hi"`))
+	assertEqual(t, "100%%", systemdQuote("100%"))
+	assertEqual(t, "$$HOME", systemdQuote("$HOME"))
+}
+
+func TestEnvFile(t *testing.T) {
+	assertEqual(t, "# moni environment, see 'moni help'\nMONIBOT_API_KEY=007\n", envFile("007"))
+	assertEqual(t, "# moni environment, see 'moni help'\n# MONIBOT_API_KEY=<your API key>\n", envFile(""))
+}
+
+func TestInstallService(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "apikey")
+	assertNil(t, os.WriteFile(keyFile, []byte("0123456789abcdef\n"), 0600))
+	envFilename := filepath.Join(dir, "moni.env")
+	install := func(args string) string {
+		os.Remove(envFilename)
+		out, exitCode := runMoni(t, "-trials 1 install-service -unitDir "+dir+" -envFile "+envFilename+" "+args,
+			apiKeyEnvKey+"=", apiKeyFileEnvKey+"="+keyFile)
+		if exitCode != 0 {
+			t.Fatalf("moni %s: exit code %d: %s", args, exitCode, out)
+		}
+		unit, err := os.ReadFile(filepath.Join(dir, "moni-sample-42.service"))
+		assertNil(t, err)
+		return string(unit)
+	}
+	// the interval is raised to its min, like sample does
+	unit := install("sample 42 1m")
+	assertEqual(t, true, strings.Contains(unit, "WatchdogSec=630\n"))
+	unit = install("-name moni-sample-42 sample 42 10m")
+	assertEqual(t, true, strings.Contains(unit, "WatchdogSec=1230\n"))
+	// the env file gets the key from the api key file
+	data, err := os.ReadFile(envFilename)
+	assertNil(t, err)
+	assertEqual(t, true, strings.Contains(string(data), "\n"+apiKeyEnvKey+"=0123456789abcdef\n"))
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// tail defaults
+const (
+	tailPollInterval       = 1 * time.Second
+	defaultTailInterval    = 1 * time.Minute
+	minTailInterval        = 10 * time.Second
+	tailFingerprintSize    = 256
+	tailStartAtEnd         = -1
+	maxTailReadSize        = 4 * 1024 * 1024
+	maxTailPartialLineSize = 64 * 1024
+)
+
+// TailRule counts log lines that match a regex into a counter metric.
+// If Value is set, it takes the values of a capture group of the regex
+// into a histogram metric instead.
+type TailRule struct {
+	Name     string
+	MetricId string
+	Match    *regexp.Regexp
+	Value    int   // capture group index, 0 means count lines
+	Round    int64 // round values to a multiple of Round, 0 means no rounding
+}
+
+// parseTailRules parses a rules definition. Each section of the
+// definition is a rule, keys are 'metric', 'match', 'value' and
+// 'round'. Value is the number or name of a capture group. Example:
+//
+//	[errors]
+//	metric = name:Errors
+//	match = ERROR
+//
+//	[durations]
+//	metric = name:RequestDuration
+//	match = took (?P<millis>[0-9.]+)ms
+//	value = millis
+//	round = 10
+func parseTailRules(r io.Reader) ([]TailRule, error) {
+	defs, err := parseSections(r)
+	if err != nil {
+		return nil, err
+	}
+	var rules []TailRule
+	for _, def := range defs {
+		rule := TailRule{Name: def.Title}
+		var value string
+		for _, kv := range def.Keys {
+			switch kv.Key {
+			case "metric":
+				rule.MetricId = kv.Value
+			case "match":
+				re, err := regexp.Compile(kv.Value)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid match: %w", kv.Line, err)
+				}
+				rule.Match = re
+			case "value":
+				value = kv.Value
+			case "round":
+				round, err := strconv.ParseInt(kv.Value, 10, 64)
+				if err != nil || round <= 0 {
+					return nil, fmt.Errorf("line %d: invalid round %q", kv.Line, kv.Value)
+				}
+				rule.Round = round
+			default:
+				return nil, fmt.Errorf("line %d: unknown key %q", kv.Line, kv.Key)
+			}
+		}
+		if rule.MetricId == "" {
+			return nil, fmt.Errorf("rule [%s]: empty metric", rule.Name)
+		}
+		if rule.Match == nil {
+			return nil, fmt.Errorf("rule [%s]: empty match", rule.Name)
+		}
+		if value != "" {
+			index, err := strconv.Atoi(value)
+			if err != nil {
+				index = rule.Match.SubexpIndex(value)
+			}
+			if index <= 0 || index > rule.Match.NumSubexp() {
+				return nil, fmt.Errorf("rule [%s]: no capture group %q", rule.Name, value)
+			}
+			rule.Value = index
+		} else if rule.Round > 0 {
+			return nil, fmt.Errorf("rule [%s]: round needs value", rule.Name)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Tailer follows a file, like 'tail -F'. It handles files that
+// are rotated (renamed and re-created) or truncated.
+type Tailer struct {
+	filename    string
+	file        *os.File
+	info        os.FileInfo
+	offset      int64  // read position in file
+	partial     []byte // incomplete last line
+	fingerprint string // fingerprint of file at offset, "" if unknown
+}
+
+// NewTailer creates a Tailer that starts reading at offset. If offset
+// is tailStartAtEnd, it starts at the end of the file. If fingerprint
+// does not match the file, the file was rotated, and the Tailer starts
+// reading at the beginning of the file.
+func NewTailer(filename string, offset int64, fingerprint string) *Tailer {
+	return &Tailer{filename: filename, offset: offset, fingerprint: fingerprint}
+}
+
+// Offset returns the offset after the last complete line read.
+func (t *Tailer) Offset() int64 {
+	return max(0, t.offset-int64(len(t.partial)))
+}
+
+// Fingerprint returns a hash of the first bytes of the file, so that a
+// rotated file can be detected after a restart.
+func (t *Tailer) Fingerprint() string {
+	if t.file == nil {
+		return ""
+	}
+	buf := make([]byte, min(tailFingerprintSize, t.Offset()))
+	n, _ := t.file.ReadAt(buf, 0)
+	return fingerprint(buf[:n])
+}
+
+func fingerprint(head []byte) string {
+	sum := sha256.Sum256(head)
+	return hex.EncodeToString(sum[:])
+}
+
+// open opens the file and seeks to the start offset.
+func (t *Tailer) open() error {
+	f, err := os.Open(t.filename)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	switch {
+	case t.offset == tailStartAtEnd:
+		t.offset = info.Size()
+	case t.offset > info.Size():
+		t.offset = 0
+	case t.fingerprint != "":
+		buf := make([]byte, min(tailFingerprintSize, t.offset))
+		n, _ := f.ReadAt(buf, 0)
+		if fingerprint(buf[:n]) != t.fingerprint {
+			t.offset = 0
+		}
+	}
+	if _, err := f.Seek(t.offset, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	t.file = f
+	t.info = info
+	t.partial = nil
+	t.fingerprint = ""
+	return nil
+}
+
+// ReadLines reads the complete lines that were appended since the last
+// call. If the file does not exist (yet), it returns no lines.
+func (t *Tailer) ReadLines() ([]string, error) {
+	if t.file == nil {
+		if err := t.open(); err != nil {
+			if os.IsNotExist(err) {
+				// when the file is created, read it from the beginning
+				t.offset = 0
+				t.fingerprint = ""
+				return nil, nil
+			}
+			return nil, err
+		}
+	}
+	lines, more, err := t.read()
+	if err != nil || more {
+		return lines, err
+	}
+	info, err := os.Stat(t.filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// rotated, new file not yet created
+			return lines, nil
+		}
+		return lines, err
+	}
+	if !os.SameFile(info, t.info) {
+		// rotated, we have read the old file completely, its last line
+		// may lack a newline, then continue with new file
+		if len(t.partial) > 0 {
+			lines = append(lines, string(bytes.TrimSuffix(t.partial, []byte("\r"))))
+			t.partial = nil
+		}
+		t.Close()
+		t.offset = 0
+		more, err := t.ReadLines()
+		return append(lines, more...), err
+	}
+	if info.Size() < t.offset {
+		// truncated, start over
+		t.offset = 0
+		t.partial = nil
+		if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+			return lines, err
+		}
+	}
+	return lines, nil
+}
+
+// read reads lines from the current offset. It reads at most
+// maxTailReadSize bytes, more is true if there may be more to read.
+func (t *Tailer) read() ([]string, bool, error) {
+	data, err := io.ReadAll(io.LimitReader(t.file, maxTailReadSize))
+	t.offset += int64(len(data))
+	more := len(data) == maxTailReadSize
+	if len(t.partial) > 0 {
+		data = append(t.partial, data...)
+		t.partial = nil
+	}
+	var lines []string
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		lines = append(lines, string(bytes.TrimSuffix(data[:i], []byte("\r"))))
+		data = data[i+1:]
+	}
+	if len(data) > maxTailPartialLineSize {
+		// a very long line without newline, take it as line
+		lines = append(lines, string(data))
+		data = nil
+	}
+	t.partial = append([]byte(nil), data...)
+	return lines, more, err
+}
+
+// Close closes the file.
+func (t *Tailer) Close() {
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+}
+
+// TailState is the persistent state of a 'moni tail' process.
+type TailState struct {
+	Offset      int64             `json:"offset"`
+	Fingerprint string            `json:"fingerprint"`
+	Pending     map[string]int64  `json:"pending"`          // metricId -> counts not yet sent
+	Values      map[string]string `json:"values,omitempty"` // metricId -> 'value:count' pairs not yet sent
+}
+
+// countLines counts lines that match rules into pending, and adds
+// captured values into values, both keyed by metric id. Captured
+// values that are not a non-negative number are skipped.
+func countLines(rules []TailRule, lines []string, pending map[string]int64, values map[string]ValueCounts) {
+	for _, line := range lines {
+		for _, rule := range rules {
+			if rule.Value == 0 {
+				if rule.Match.MatchString(line) {
+					pending[rule.MetricId]++
+				}
+				continue
+			}
+			match := rule.Match.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			f, err := strconv.ParseFloat(match[rule.Value], 64)
+			if err != nil || f < 0 || f > math.MaxInt64/2 {
+				continue
+			}
+			if values[rule.MetricId] == nil {
+				values[rule.MetricId] = make(ValueCounts)
+			}
+			values[rule.MetricId].Add(roundValue(int64(math.Round(f)), rule.Round))
+		}
+	}
+}
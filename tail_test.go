@@ -0,0 +1,207 @@
+package main
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTailRules(t *testing.T) {
+	rules, err := parseTailRules(strings.NewReader(`
+[errors]
+metric = name:Errors
+match = ERROR
+
+[timeouts]
+metric = 42
+match = timeout after \d+s
+`))
+	assertNil(t, err)
+	assertEqual(t, 2, len(rules))
+	assertEqual(t, "errors", rules[0].Name)
+	assertEqual(t, "name:Errors", rules[0].MetricId)
+	assertEqual(t, "ERROR", rules[0].Match.String())
+	assertEqual(t, "timeouts", rules[1].Name)
+	assertEqual(t, "42", rules[1].MetricId)
+	assertEqual(t, `timeout after \d+s`, rules[1].Match.String())
+	assertEqual(t, 0, rules[1].Value)
+	// values
+	rules, err = parseTailRules(strings.NewReader(`
+[durations]
+metric = 43
+match = took (?P<millis>[0-9.]+)ms
+value = millis
+round = 10
+[sizes]
+metric = 44
+match = (GET|POST) .* ([0-9]+) bytes
+value = 2
+`))
+	assertNil(t, err)
+	assertEqual(t, 2, len(rules))
+	assertEqual(t, 1, rules[0].Value)
+	assertEqual(t, int64(10), rules[0].Round)
+	assertEqual(t, 2, rules[1].Value)
+	assertEqual(t, int64(0), rules[1].Round)
+	// errors
+	parseErr := func(s string) string {
+		_, err := parseTailRules(strings.NewReader(s))
+		if err == nil {
+			return ""
+		}
+		return err.Error()
+	}
+	assertEqual(t, "no sections", parseErr("# empty"))
+	assertEqual(t, `line 2: unknown key "metrc"`, parseErr("[A]\nmetrc = 42"))
+	assertEqual(t, "rule [A]: empty metric", parseErr("[A]\nmatch = x"))
+	assertEqual(t, "rule [A]: empty match", parseErr("[A]\nmetric = 42"))
+	assertEqual(t, `rule [A]: no capture group "1"`, parseErr("[A]\nmetric = 42\nmatch = x\nvalue = 1"))
+	assertEqual(t, `rule [A]: no capture group "ms"`, parseErr("[A]\nmetric = 42\nmatch = (x)\nvalue = ms"))
+	assertEqual(t, `line 4: invalid round "0"`, parseErr("[A]\nmetric = 42\nmatch = (x)\nround = 0"))
+	assertEqual(t, "rule [A]: round needs value", parseErr("[A]\nmetric = 42\nmatch = (x)\nround = 5"))
+	assertEqual(t, true, strings.HasPrefix(parseErr("[A]\nmetric = 42\nmatch = ("), "line 3: invalid match: "))
+}
+
+func TestTailer(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "app.log")
+	write := func(s string) {
+		f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		assertNil(t, err)
+		_, err = f.WriteString(s)
+		assertNil(t, err)
+		assertNil(t, f.Close())
+	}
+	readLines := func(tailer *Tailer) string {
+		lines, err := tailer.ReadLines()
+		assertNil(t, err)
+		return strings.Join(lines, "|")
+	}
+	// file does not exist yet
+	tailer := NewTailer(filename, tailStartAtEnd, "")
+	defer tailer.Close()
+	assertEqual(t, "", readLines(tailer))
+	// file is created, read from start
+	write("one\ntwo\n")
+	assertEqual(t, "one|two", readLines(tailer))
+	assertEqual(t, "", readLines(tailer))
+	// partial lines
+	write("thr")
+	assertEqual(t, "", readLines(tailer))
+	assertEqual(t, int64(8), tailer.Offset())
+	write("ee\r\nfour\n")
+	assertEqual(t, "three|four", readLines(tailer))
+	assertEqual(t, int64(20), tailer.Offset())
+	// truncated
+	assertNil(t, os.Truncate(filename, 0))
+	assertEqual(t, "", readLines(tailer))
+	write("five\n")
+	assertEqual(t, "five", readLines(tailer))
+	// rotated, lines appended to old file before rotation are read
+	write("six\n")
+	assertNil(t, os.Rename(filename, filename+".1"))
+	assertEqual(t, "six", readLines(tailer))
+	write("seven\n")
+	assertEqual(t, "seven", readLines(tailer))
+	// rotated, the old file ends without newline
+	write("half")
+	assertEqual(t, "", readLines(tailer))
+	assertNil(t, os.Rename(filename, filename+".2"))
+	write("new\n")
+	assertEqual(t, "half|new", readLines(tailer))
+	// restart with offset and fingerprint
+	offset, fingerprint := tailer.Offset(), tailer.Fingerprint()
+	tailer.Close()
+	write("eight\n")
+	tailer = NewTailer(filename, offset, fingerprint)
+	assertEqual(t, "eight", readLines(tailer))
+	// restart after rotation, fingerprint does not match
+	offset, fingerprint = tailer.Offset(), tailer.Fingerprint()
+	tailer.Close()
+	assertNil(t, os.Remove(filename))
+	write("nine\nten\nelevn\ntwelve\n")
+	tailer = NewTailer(filename, offset, fingerprint)
+	assertEqual(t, "nine|ten|elevn|twelve", readLines(tailer))
+	// start at end
+	tailer.Close()
+	tailer = NewTailer(filename, tailStartAtEnd, "")
+	assertEqual(t, "", readLines(tailer))
+	write("thirteen\n")
+	assertEqual(t, "thirteen", readLines(tailer))
+}
+
+func TestCountLines(t *testing.T) {
+	rules, err := parseTailRules(strings.NewReader(`
+[errors]
+metric = 1
+match = ERROR
+[warnings]
+metric = 2
+match = WARN|ERROR
+[durations]
+metric = 3
+match = took (-?[0-9.a-z]+)ms
+value = 1
+round = 10
+`))
+	assertNil(t, err)
+	pending := map[string]int64{"1": 3}
+	values := map[string]ValueCounts{}
+	countLines(rules, []string{
+		"INFO ok took 12ms",
+		"ERROR bad",
+		"WARN hmm took 14.9ms",
+		"ERROR worse took 26ms",
+		"INFO took -3ms",
+		"INFO took xms",
+	}, pending, values)
+	assertEqual(t, int64(5), pending["1"])
+	assertEqual(t, int64(3), pending["2"])
+	assertEqual(t, int64(0), pending["3"])
+	assertEqual(t, "10,20,30", values["3"].String())
+}
+
+// TestTailIdleWatchdog runs the tail loop in a child process and checks
+// that it pings the systemd watchdog although there is nothing to send.
+func TestTailIdleWatchdog(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs unixgram sockets")
+	}
+	dir := t.TempDir()
+	logfile := filepath.Join(dir, "app.log")
+	rulesfile := filepath.Join(dir, "rules.ini")
+	assertNil(t, os.WriteFile(logfile, nil, 0600))
+	assertNil(t, os.WriteFile(rulesfile, []byte("[errors]\nmetric = 42\nmatch = ERROR\n"), 0600))
+	socket := filepath.Join(dir, "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	assertNil(t, err)
+	defer conn.Close()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(),
+		"MONI_TEST_MAIN=1",
+		"MONI_TEST_ARGS=-dev tail "+logfile+" "+rulesfile+" -interval 1s",
+		"NOTIFY_SOCKET="+socket,
+		apiKeyEnvKey+"=0123456789abcdef",
+		urlEnvKey+"=http://127.0.0.1:1",
+		"MONIBOT_STATE_DIR="+dir,
+	)
+	assertNil(t, cmd.Start())
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+	receive := func() string {
+		conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+		buf := make([]byte, 1024)
+		n, err := conn.Read(buf)
+		assertNil(t, err)
+		return string(buf[:n])
+	}
+	assertEqual(t, "READY=1", receive())
+	assertEqual(t, "WATCHDOG=1", receive())
+	assertEqual(t, "WATCHDOG=1", receive())
+}
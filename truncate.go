@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// truncate modes, they name the part of a text that is omitted
+const (
+	truncateHead   = "head"
+	truncateTail   = "tail"
+	truncateMiddle = "middle"
+)
+
+// truncateLineSlack is the max. number of bytes that truncateText
+// drops in addition, so that it can cut at a line boundary.
+const truncateLineSlack = 1024
+
+// isTruncateMode returns true if mode is a valid truncate mode.
+func isTruncateMode(mode string) bool {
+	return mode == truncateHead || mode == truncateTail || mode == truncateMiddle
+}
+
+// truncateText truncates text to at most maxSize bytes, if it is bigger.
+// The omitted part is replaced by a marker like "... N bytes omitted ...".
+// Cuts are made at line boundaries if possible, and never within
+// an UTF-8 encoded rune.
+func truncateText(text string, maxSize int, mode string) string {
+	if len(text) <= maxSize {
+		return text
+	}
+	budget := max(0, maxSize-len(omittedMarker(len(text)))-1)
+	switch mode {
+	case truncateHead:
+		tail := text[tailCut(text, budget):]
+		return omittedMarker(len(text)-len(tail)) + tail
+	case truncateMiddle:
+		head := text[:headCut(text, budget/2)]
+		tail := text[tailCut(text, budget-budget/2):]
+		return withNewline(head) + omittedMarker(len(text)-len(head)-len(tail)) + tail
+	}
+	head := text[:headCut(text, budget)]
+	return withNewline(head) + omittedMarker(len(text)-len(head))
+}
+
+func omittedMarker(n int) string {
+	return fmt.Sprintf("... %d bytes omitted ...\n", n)
+}
+
+// withNewline appends a newline to s if s does not end with one.
+func withNewline(s string) string {
+	if s == "" || strings.HasSuffix(s, "\n") {
+		return s
+	}
+	return s + "\n"
+}
+
+// headCut returns the end index of the head of text that has at most n bytes.
+func headCut(text string, n int) int {
+	i := n
+	if j := strings.LastIndexByte(text[:i], '\n'); j >= 0 && i-j <= truncateLineSlack {
+		return j + 1
+	}
+	for i > 0 && !utf8.RuneStart(text[i]) {
+		i--
+	}
+	return i
+}
+
+// tailCut returns the start index of the tail of text that has at most n bytes.
+func tailCut(text string, n int) int {
+	i := len(text) - n
+	if i > 0 && text[i-1] == '\n' {
+		return i
+	}
+	if j := strings.IndexByte(text[i:], '\n'); j >= 0 && j < truncateLineSlack {
+		return i + j + 1
+	}
+	for i < len(text) && !utf8.RuneStart(text[i]) {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateText(t *testing.T) {
+	var text string
+	for i := 1; i <= 10; i++ {
+		text += fmt.Sprintf("line %02d\n", i)
+	}
+	// small texts are not truncated
+	assertEqual(t, text, truncateText(text, 100, truncateTail))
+	assertEqual(t, text, truncateText(text, len(text), truncateHead))
+	// cut at line boundaries
+	assertEqual(t, "line 01\nline 02\nline 03\n... 56 bytes omitted ...\n", truncateText(text, 50, truncateTail))
+	assertEqual(t, "... 56 bytes omitted ...\nline 08\nline 09\nline 10\n", truncateText(text, 50, truncateHead))
+	assertEqual(t, "line 01\nline 02\n... 48 bytes omitted ...\nline 09\nline 10\n", truncateText(text, 60, truncateMiddle))
+	// cut at rune boundaries
+	text = strings.Repeat("äöü", 20)
+	for _, mode := range []string{truncateHead, truncateTail, truncateMiddle} {
+		for size := 30; size < 40; size++ {
+			s := truncateText(text, size, mode)
+			assertEqual(t, true, len(s) <= size)
+			assertEqual(t, true, utf8.ValidString(s))
+			assertEqual(t, true, strings.Contains(s, " bytes omitted ..."))
+		}
+	}
+	assertEqual(t, "äöüäöü\n... 108 bytes omitted ...\n", truncateText(text, 40, truncateTail))
+}
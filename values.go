@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// max. payload of a single 'values' request, larger payloads are split
+const (
+	maxValuesRequestSize  = 32 * 1024 // length of 'value:count' pairs
+	maxValuesRequestCount = 100_000   // sum of counts
+)
+
+// ValueCounts aggregates histogram values, it maps value to count.
+type ValueCounts map[int64]int64
+
+// parseValueCounts parses a comma-separated list of 'value:count' pairs.
+// Values are converted with parseMetricValue.
+func parseValueCounts(s string, scale float64) (ValueCounts, error) {
+	vc := make(ValueCounts)
+	if err := vc.parse(s, scale); err != nil {
+		return nil, err
+	}
+	return vc, nil
+}
+
+// parse adds a comma-separated list of 'value:count' pairs to vc.
+// Counts are added as they are, a pair is never expanded into
+// count values.
+func (vc ValueCounts) parse(s string, scale float64) error {
+	for _, tok := range strings.Split(s, ",") {
+		vs, cs, found := strings.Cut(tok, ":")
+		v, err := parseMetricValue(vs, scale)
+		if err != nil {
+			return err
+		}
+		c := int64(1)
+		if found {
+			cs = strings.TrimSpace(cs)
+			c, err = strconv.ParseInt(cs, 10, 64)
+			if err != nil || c < 1 {
+				return fmt.Errorf("invalid count %q", cs)
+			}
+		}
+		if vc[v] > math.MaxInt64-c {
+			return fmt.Errorf("count of value %d out of range", v)
+		}
+		vc[v] += c
+	}
+	return nil
+}
+
+// readValueCounts reads values from r, one value or 'value:count'
+// pair per line. Empty lines and lines starting with '#' are ignored.
+// Values are converted with parseMetricValue.
+func readValueCounts(r io.Reader, scale float64) (ValueCounts, error) {
+	vc := make(ValueCounts)
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := vc.parse(line, scale); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(vc) == 0 {
+		return nil, fmt.Errorf("no values")
+	}
+	return vc, nil
+}
+
+// Add adds values.
+func (vc ValueCounts) Add(values ...int64) {
+	for _, v := range values {
+		vc[v]++
+	}
+}
+
+// Values returns all values in ascending order, each value
+// repeated count times.
+func (vc ValueCounts) Values() []int64 {
+	var values []int64
+	for _, v := range vc.keys() {
+		for range vc[v] {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// String returns the values as comma-separated list of 'value:count'
+// pairs in ascending order, e.g. "13:2,14".
+func (vc ValueCounts) String() string {
+	toks := make([]string, 0, len(vc))
+	for _, v := range vc.keys() {
+		toks = append(toks, valueCountToken(v, vc[v]))
+	}
+	return strings.Join(toks, ",")
+}
+
+func valueCountToken(v, count int64) string {
+	if count == 1 {
+		return strconv.FormatInt(v, 10)
+	}
+	return strconv.FormatInt(v, 10) + ":" + strconv.FormatInt(count, 10)
+}
+
+// Split splits vc into chunks, each chunk has a 'value:count' encoding
+// of at most maxSize bytes (or a single pair) and a sum of counts of
+// at most maxCount. The count of a value may be split across chunks.
+func (vc ValueCounts) Split(maxSize int, maxCount int64) []ValueCounts {
+	var chunks []ValueCounts
+	chunk := make(ValueCounts)
+	size, count := 0, int64(0)
+	for _, v := range vc.keys() {
+		for c := vc[v]; c > 0; {
+			n := min(c, maxCount-count)
+			tokSize := len(valueCountToken(v, n)) + 1 // plus comma
+			if len(chunk) > 0 && (n == 0 || size+tokSize > maxSize+1) {
+				chunks = append(chunks, chunk)
+				chunk = make(ValueCounts)
+				size, count = 0, 0
+				continue
+			}
+			chunk[v] = n
+			size += tokSize
+			count += n
+			c -= n
+		}
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// Send sends vc in chunks, see Split, and removes what was sent from
+// vc. It stops at the first error, then vc holds what was not sent.
+func (vc ValueCounts) Send(send func(values []int64) error) error {
+	for _, chunk := range vc.Split(maxValuesRequestSize, maxValuesRequestCount) {
+		if err := send(chunk.Values()); err != nil {
+			return err
+		}
+		for v, c := range chunk {
+			vc[v] -= c
+			if vc[v] <= 0 {
+				delete(vc, v)
+			}
+		}
+	}
+	return nil
+}
+
+func (vc ValueCounts) keys() []int64 {
+	keys := make([]int64, 0, len(vc))
+	for v := range vc {
+		keys = append(keys, v)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// roundValue rounds v to the nearest multiple of round.
+// If round is 0 or less, v is returned unchanged.
+func roundValue(v, round int64) int64 {
+	if round <= 0 {
+		return v
+	}
+	return (v + round/2) / round * round
+}
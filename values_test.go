@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestValueCounts(t *testing.T) {
+	vc, err := parseValueCounts("14,13:2,13:1,0", 1)
+	assertNil(t, err)
+	assertEqual(t, "0,13:3,14", vc.String())
+	assertEqual(t, "[0 13 13 13 14]", fmt.Sprint(vc.Values()))
+	vc.Add(14, 15)
+	assertEqual(t, "0,13:3,14:2,15", vc.String())
+	assertEqual(t, "", ValueCounts{}.String())
+	_, err = parseValueCounts("13:x", 1)
+	assertEqual(t, false, err == nil)
+	_, err = parseValueCounts("-1", 1)
+	assertEqual(t, false, err == nil)
+	_, err = parseValueCounts("1,-2:3", 1)
+	assertEqual(t, `negative value "-2" not allowed`, err.Error())
+	_, err = parseValueCounts("13:0", 1)
+	assertEqual(t, `invalid count "0"`, err.Error())
+	vc, err = parseValueCounts("1.5s:2,2ms,3", 1)
+	assertNil(t, err)
+	assertEqual(t, "2,3,1500:2", vc.String())
+	// huge counts are not expanded into values
+	vc, err = parseValueCounts("5:1000000000,5:9000000000000000000", 1)
+	assertNil(t, err)
+	assertEqual(t, "5:9000000001000000000", vc.String())
+	_, err = parseValueCounts("5:9223372036854775807,5", 1)
+	assertEqual(t, "count of value 5 out of range", err.Error())
+}
+
+func TestRoundValue(t *testing.T) {
+	assertEqual(t, int64(17), roundValue(17, 0))
+	assertEqual(t, int64(20), roundValue(17, 10))
+	assertEqual(t, int64(10), roundValue(14, 10))
+	assertEqual(t, int64(20), roundValue(15, 10))
+	assertEqual(t, int64(0), roundValue(4, 10))
+	assertEqual(t, int64(100), roundValue(149, 100))
+}
+
+func TestReadValueCounts(t *testing.T) {
+	vc, err := readValueCounts(strings.NewReader("# values\n13\n\n14:2\r\n 13:3 \n15,16:2\n"), 1)
+	assertNil(t, err)
+	assertEqual(t, "13:4,14:2,15,16:2", vc.String())
+	_, err = readValueCounts(strings.NewReader("13\nx\n"), 1)
+	assertEqual(t, `line 2: invalid value "x"`, err.Error())
+	_, err = readValueCounts(strings.NewReader("# nothing\n"), 1)
+	assertEqual(t, "no values", err.Error())
+	vc, err = readValueCounts(strings.NewReader("1.5ms\n2ms:2\n1500us\n"), 1000)
+	assertNil(t, err)
+	assertEqual(t, "1500:2,2000:2", vc.String())
+}
+
+func TestValueCountsSplit(t *testing.T) {
+	split := func(vc ValueCounts, maxSize int, maxCount int64) string {
+		var toks []string
+		for _, chunk := range vc.Split(maxSize, maxCount) {
+			toks = append(toks, chunk.String())
+		}
+		return strings.Join(toks, " | ")
+	}
+	vc := ValueCounts{1: 1, 22: 2, 333: 3, 4444: 4}
+	assertEqual(t, "1,22:2,333:3,4444:4", split(vc, 100, 100))
+	// by size
+	assertEqual(t, "1,22:2 | 333:3 | 4444:4", split(vc, 8, 100))
+	assertEqual(t, "1 | 22:2 | 333:3 | 4444:4", split(vc, 1, 100))
+	// by count
+	assertEqual(t, "1,22:2 | 333:3 | 4444:3 | 4444", split(vc, 100, 3))
+	assertEqual(t, "1,22:2,333 | 333:2,4444:2 | 4444:2", split(vc, 100, 4))
+	// total values are preserved
+	var total int
+	for _, chunk := range (ValueCounts{7: 250_001}).Split(maxValuesRequestSize, maxValuesRequestCount) {
+		total += len(chunk.Values())
+	}
+	assertEqual(t, 250_001, total)
+	assertEqual(t, "", split(ValueCounts{}, 100, 100))
+}
+
+func TestValueCountsSend(t *testing.T) {
+	var requests []int
+	send := func(values []int64) error {
+		if len(requests) == 2 {
+			return fmt.Errorf("failed")
+		}
+		requests = append(requests, len(values))
+		return nil
+	}
+	vc := ValueCounts{5: 250_000, 6: 1}
+	err := vc.Send(send)
+	assertEqual(t, "failed", err.Error())
+	assertEqual(t, "[100000 100000]", fmt.Sprint(requests))
+	// what was not sent remains
+	assertEqual(t, "5:50000,6", vc.String())
+	requests = nil
+	assertNil(t, vc.Send(send))
+	assertEqual(t, 0, len(vc))
+}